@@ -1,60 +1,179 @@
 package utils
 
 import (
-    "bytes"
+    "context"
+    "errors"
     "fmt"
     "os"
     "os/exec"
     "strings"
 )
 
+// CommandErrorKind classifies why a command did not complete successfully.
+type CommandErrorKind string
+
+const (
+    CommandTimedOut  CommandErrorKind = "TIMEOUT"
+    CommandCancelled CommandErrorKind = "CANCELLED"
+    CommandExited    CommandErrorKind = "EXIT"
+)
+
+// CommandError is returned by the context-aware helpers when a command times out,
+// is cancelled, or exits with a non-zero status.
+type CommandError struct {
+    Kind     CommandErrorKind
+    Command  string
+    ExitCode int
+    Err      error
+}
+
+// Error implements the error interface.
+func (e *CommandError) Error() string {
+    switch e.Kind {
+    case CommandTimedOut:
+        return fmt.Sprintf("command timed out: %s", e.Command)
+    case CommandCancelled:
+        return fmt.Sprintf("command cancelled: %s", e.Command)
+    default:
+        if e.ExitCode < 0 && e.Err != nil {
+            return fmt.Sprintf("command failed (%v): %s", e.Err, e.Command)
+        }
+        return fmt.Sprintf("command exited with code %d: %s", e.ExitCode, e.Command)
+    }
+}
+
+// Unwrap returns the underlying context or *exec.ExitError.
+func (e *CommandError) Unwrap() error {
+    return e.Err
+}
+
+// IsCommandTimeout reports whether err is a CommandError caused by a deadline.
+func IsCommandTimeout(err error) bool {
+    var cmdErr *CommandError
+    return errors.As(err, &cmdErr) && cmdErr.Kind == CommandTimedOut
+}
+
+// IsCommandCancelled reports whether err is a CommandError caused by cancellation.
+func IsCommandCancelled(err error) bool {
+    var cmdErr *CommandError
+    return errors.As(err, &cmdErr) && cmdErr.Kind == CommandCancelled
+}
+
 // RunCommand executes an inline Bash command string and returns combined stdout/stderr output.
 func RunCommand(command string) (string, error) {
-    cmd := exec.Command("bash", "-c", command)
-    var out bytes.Buffer
-    cmd.Stdout = &out
-    cmd.Stderr = &out
-
-    err := cmd.Run()
-    return strings.TrimSpace(out.String()), err
+    return RunCommandContext(context.Background(), command)
 }
 
 // RunScript executes a Bash script file located at scriptPath with optional args.
+// The path and args are passed as argv, never through a shell string.
 // Returns combined stdout/stderr output.
 func RunScript(scriptPath string, args ...string) (string, error) {
-    cmd := exec.Command("bash", append([]string{scriptPath}, args...)...)
-    var out bytes.Buffer
-    cmd.Stdout = &out
-    cmd.Stderr = &out
-
-    err := cmd.Run()
-    return strings.TrimSpace(out.String()), err
+    return RunScriptContext(context.Background(), scriptPath, args...)
 }
 
 // RunCommandWithEnv runs a Bash command with additional environment variables.
 func RunCommandWithEnv(command string, envVars map[string]string) (string, error) {
-    cmd := exec.Command("bash", "-c", command)
-    var out bytes.Buffer
-    cmd.Stdout = &out
-    cmd.Stderr = &out
-
-    env := os.Environ()
-    for k, v := range envVars {
-        env = append(env, fmt.Sprintf("%s=%s", k, v))
-    }
-    cmd.Env = env
-
-    err := cmd.Run()
-    return strings.TrimSpace(out.String()), err
+    return RunCommandWithEnvContext(context.Background(), command, envVars)
 }
 
 // RunAndCapture splits output and error separately.
 func RunAndCapture(command string) (stdout string, stderr string, err error) {
-    cmd := exec.Command("bash", "-c", command)
-    var outBuf, errBuf bytes.Buffer
-    cmd.Stdout = &outBuf
-    cmd.Stderr = &errBuf
+    return RunAndCaptureContext(context.Background(), command)
+}
+
+// RunCommandContext executes an inline Bash command string under ctx and returns combined stdout/stderr output.
+// When ctx is done the whole process group is killed.
+func RunCommandContext(ctx context.Context, command string) (string, error) {
+    res, err := runResult(ctx, newBashCommand(ctx, "-c", command), command, ExecOptions{}, false)
+    return strings.TrimSpace(res.Combined), err
+}
+
+// RunScriptContext executes a Bash script file under ctx with optional args.
+// Returns combined stdout/stderr output.
+func RunScriptContext(ctx context.Context, scriptPath string, args ...string) (string, error) {
+    res, err := runResult(ctx, newBashCommand(ctx, scriptArgs(scriptPath, args)...), scriptPath, ExecOptions{}, false)
+    return strings.TrimSpace(res.Combined), err
+}
 
-    err = cmd.Run()
-    return strings.TrimSpace(outBuf.String()), strings.TrimSpace(errBuf.String()), err
+// RunCommandWithEnvContext runs a Bash command under ctx with additional environment variables.
+func RunCommandWithEnvContext(ctx context.Context, command string, envVars map[string]string) (string, error) {
+    cmd := newBashCommand(ctx, "-c", command)
+    cmd.Env = mergeEnv(os.Environ(), envVars)
+    res, err := runResult(ctx, cmd, command, ExecOptions{}, false)
+    return strings.TrimSpace(res.Combined), err
+}
+
+// RunAndCaptureContext runs a Bash command under ctx, returning stdout and stderr separately.
+func RunAndCaptureContext(ctx context.Context, command string) (stdout string, stderr string, err error) {
+    res, err := RunCommandResult(ctx, command)
+    return strings.TrimSpace(res.Stdout), strings.TrimSpace(res.Stderr), err
+}
+
+// RunCommandResult executes an inline Bash command under ctx and returns a structured result.
+// The result is always non-nil, even when an error is returned.
+func RunCommandResult(ctx context.Context, command string) (*CommandResult, error) {
+    return runResult(ctx, newBashCommand(ctx, "-c", command), command, ExecOptions{}, true)
+}
+
+// RunScriptResult executes a Bash script file under ctx and returns a structured result.
+func RunScriptResult(ctx context.Context, scriptPath string, args ...string) (*CommandResult, error) {
+    return runResult(ctx, newBashCommand(ctx, scriptArgs(scriptPath, args)...), scriptPath, ExecOptions{}, true)
+}
+
+// RunCommandWithEnvResult executes a Bash command under ctx with additional environment variables
+// and returns a structured result.
+func RunCommandWithEnvResult(ctx context.Context, command string, envVars map[string]string) (*CommandResult, error) {
+    cmd := newBashCommand(ctx, "-c", command)
+    cmd.Env = mergeEnv(os.Environ(), envVars)
+    return runResult(ctx, cmd, command, ExecOptions{}, true)
+}
+
+// newBashCommand builds a bash invocation bound to ctx that runs in its own process group.
+func newBashCommand(ctx context.Context, args ...string) *exec.Cmd {
+    cmd := exec.CommandContext(ctx, "bash", args...)
+    setProcessGroup(cmd)
+    return cmd
+}
+
+// scriptArgs builds bash argv for a script file. The "--" stops a path beginning with "-"
+// from being parsed as a bash option.
+func scriptArgs(scriptPath string, args []string) []string {
+    return append([]string{"--", scriptPath}, args...)
+}
+
+// runCommand runs cmd and converts context and exit failures into a *CommandError.
+func runCommand(ctx context.Context, cmd *exec.Cmd, label string) error {
+    return classifyCommandError(ctx, cmd.Run(), label)
+}
+
+// classifyCommandError wraps err in a *CommandError when it was caused by ctx or a non-zero exit.
+func classifyCommandError(ctx context.Context, err error, label string) error {
+    if err == nil || errors.Is(err, exec.ErrWaitDelay) {
+        // ErrWaitDelay means the command succeeded but a background child kept its output open.
+        return nil
+    }
+
+    switch ctx.Err() {
+    case context.DeadlineExceeded:
+        return &CommandError{Kind: CommandTimedOut, Command: label, ExitCode: -1, Err: ctx.Err()}
+    case context.Canceled:
+        return &CommandError{Kind: CommandCancelled, Command: label, ExitCode: -1, Err: ctx.Err()}
+    }
+
+    var exitErr *exec.ExitError
+    if errors.As(err, &exitErr) {
+        return &CommandError{Kind: CommandExited, Command: label, ExitCode: exitErr.ExitCode(), Err: err}
+    }
+    return err
+}
+
+// mergeEnv appends envVars to base as KEY=VALUE pairs. The result is never nil,
+// so an empty environment is not mistaken for "inherit" by exec.Cmd.
+func mergeEnv(base []string, envVars map[string]string) []string {
+    env := make([]string, 0, len(base)+len(envVars))
+    env = append(env, base...)
+    for k, v := range envVars {
+        env = append(env, fmt.Sprintf("%s=%s", k, v))
+    }
+    return env
 }
@@ -0,0 +1,140 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "io"
+    "os/exec"
+    "strings"
+)
+
+// Command describes a program invocation that is executed directly, without a shell,
+// so arguments are never re-parsed or expanded.
+type Command struct {
+    program string
+    args    []string
+    stdin   io.Reader
+    opts    ExecOptions
+}
+
+// NewCommand returns a Command that runs program with the given argv.
+func NewCommand(program string, args ...string) *Command {
+    return &Command{
+        program: program,
+        args:    append([]string(nil), args...),
+    }
+}
+
+// NewBashTemplate returns a Command that runs a fixed Bash script with values passed as
+// positional parameters ($1, $2, ...). Values are never spliced into the script text,
+// so the script must reference them as quoted parameters, e.g. `ls -- "$1"`.
+func NewBashTemplate(script string, values ...string) *Command {
+    return NewCommand("bash", append([]string{"-c", script, "bash"}, values...)...)
+}
+
+// Arg appends further arguments to the command.
+func (c *Command) Arg(args ...string) *Command {
+    c.args = append(c.args, args...)
+    return c
+}
+
+// Env sets an environment variable for the command in addition to the inherited environment.
+func (c *Command) Env(key, value string) *Command {
+    if c.opts.Env == nil {
+        c.opts.Env = make(map[string]string)
+    }
+    c.opts.Env[key] = value
+    return c
+}
+
+// Dir sets the working directory for the command.
+func (c *Command) Dir(dir string) *Command {
+    c.opts.Dir = dir
+    return c
+}
+
+// Options replaces the command's execution options, including any Env or Dir set earlier.
+func (c *Command) Options(opts ExecOptions) *Command {
+    c.opts = opts
+    return c
+}
+
+// Stdin sets the reader used as the command's standard input.
+func (c *Command) Stdin(r io.Reader) *Command {
+    c.stdin = r
+    return c
+}
+
+// String returns the command as a shell-quoted string, suitable for logging.
+func (c *Command) String() string {
+    return ShellJoin(append([]string{c.program}, c.args...)...)
+}
+
+// Run executes the command under ctx and returns a structured result.
+func (c *Command) Run(ctx context.Context) (*CommandResult, error) {
+    return c.run(ctx, true)
+}
+
+// Output executes the command under ctx and returns its trimmed combined stdout/stderr output.
+// Both streams share one pipe, so the output keeps the order the command wrote it in.
+func (c *Command) Output(ctx context.Context) (string, error) {
+    res, err := c.run(ctx, false)
+    return strings.TrimSpace(res.Combined), err
+}
+
+// run builds and executes the command; split selects separate stdout/stderr capture.
+func (c *Command) run(ctx context.Context, split bool) (*CommandResult, error) {
+    if c.program == "" {
+        return &CommandResult{ExitCode: -1}, errors.New("command program must not be empty")
+    }
+
+    cmd, err := c.build(ctx)
+    if err != nil {
+        return &CommandResult{Command: c.String(), ExitCode: -1}, err
+    }
+    return runResult(ctx, cmd, c.String(), c.opts, split)
+}
+
+// build creates the underlying exec.Cmd bound to ctx in its own process group,
+// with the command's execution options applied.
+func (c *Command) build(ctx context.Context) (*exec.Cmd, error) {
+    program, args := c.opts.wrap(c.program, c.args)
+    cmd := exec.CommandContext(ctx, program, args...)
+    setProcessGroup(cmd)
+    cmd.Stdin = c.stdin
+    if err := c.opts.apply(cmd); err != nil {
+        return nil, err
+    }
+    return cmd, nil
+}
+
+// ShellQuote quotes s so that Bash treats it as a single literal word.
+func ShellQuote(s string) string {
+    if s == "" {
+        return "''"
+    }
+    if strings.IndexFunc(s, needsShellQuote) < 0 {
+        return s
+    }
+    return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ShellJoin quotes each argument with ShellQuote and joins them with spaces.
+func ShellJoin(args ...string) string {
+    quoted := make([]string, len(args))
+    for i, arg := range args {
+        quoted[i] = ShellQuote(arg)
+    }
+    return strings.Join(quoted, " ")
+}
+
+// needsShellQuote reports whether r is outside the set of characters that are safe unquoted.
+func needsShellQuote(r rune) bool {
+    switch {
+    case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+        return false
+    case strings.ContainsRune("@%+=:,./_-", r):
+        return false
+    }
+    return true
+}
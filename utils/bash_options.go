@@ -0,0 +1,141 @@
+package utils
+
+import (
+    "context"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+)
+
+// ExecOptions controls the environment, identity and resource limits of an executed command.
+// The zero value inherits everything from the current process, as the plain helpers do.
+//
+// Env is applied on top of the base environment. CleanEnv starts from an empty environment;
+// otherwise a non-nil EnvAllowlist keeps only the named variables from the current one.
+// Nice runs the command through nice(1); if the niceness cannot be set, for example when
+// raising priority without privileges, the command is not started and an error is
+// returned. MaxOutputBytes caps each captured output buffer; excess output is discarded
+// and CommandResult.Truncated is set. User, Limits and Nice are not supported on Windows;
+// running a command that sets them fails there.
+type ExecOptions struct {
+    Dir            string
+    Env            map[string]string
+    CleanEnv       bool
+    EnvAllowlist   []string
+    User           *ExecUser
+    Limits         ResourceLimits
+    Nice           int
+    MaxOutputBytes int64
+}
+
+// ExecUser is the numeric user and group a command is run as. Switching requires privileges.
+type ExecUser struct {
+    UID uint32
+    GID uint32
+}
+
+// ResourceLimits are hard and soft rlimits applied to a command and everything it spawns.
+// Zero fields are left unlimited. MemoryBytes limits the virtual address space.
+type ResourceLimits struct {
+    CPUSeconds    uint64
+    MemoryBytes   uint64
+    OpenFiles     uint64
+    FileSizeBytes uint64
+}
+
+// RunCommandWithOptions executes an inline Bash command under ctx with the given execution options.
+func RunCommandWithOptions(ctx context.Context, command string, opts ExecOptions) (*CommandResult, error) {
+    return NewCommand("bash", "-c", command).Options(opts).Run(ctx)
+}
+
+// RunScriptWithOptions executes a Bash script file under ctx with the given execution options.
+func RunScriptWithOptions(ctx context.Context, scriptPath string, args []string, opts ExecOptions) (*CommandResult, error) {
+    return NewCommand("bash", scriptArgs(scriptPath, args)...).Options(opts).Run(ctx)
+}
+
+// isZero reports whether no limits are set.
+func (l ResourceLimits) isZero() bool {
+    return l == ResourceLimits{}
+}
+
+// ulimitArgs returns the bash ulimit flags for the configured limits.
+// Bash expresses -v and -f in KiB, so byte values are rounded up.
+func (l ResourceLimits) ulimitArgs() []string {
+    var args []string
+    if l.CPUSeconds > 0 {
+        args = append(args, "-t", strconv.FormatUint(l.CPUSeconds, 10))
+    }
+    if l.MemoryBytes > 0 {
+        args = append(args, "-v", strconv.FormatUint((l.MemoryBytes+1023)/1024, 10))
+    }
+    if l.OpenFiles > 0 {
+        args = append(args, "-n", strconv.FormatUint(l.OpenFiles, 10))
+    }
+    if l.FileSizeBytes > 0 {
+        args = append(args, "-f", strconv.FormatUint((l.FileSizeBytes+1023)/1024, 10))
+    }
+    return args
+}
+
+// wrap returns the program and argv to execute. A niceness is applied by running the
+// command under nice -n. When limits are set the command is exec'd from a bash wrapper
+// that applies them with ulimit first; the original program and args are passed as
+// positional parameters, never spliced into the script.
+func (o ExecOptions) wrap(program string, args []string) (string, []string) {
+    if o.Nice != 0 {
+        args = append([]string{"-n", strconv.Itoa(o.Nice), "--", program}, args...)
+        program = "nice"
+    }
+    if o.Limits.isZero() {
+        return program, args
+    }
+    script := "ulimit " + strings.Join(o.Limits.ulimitArgs(), " ") + ` && exec "$@"`
+    return "bash", append([]string{"-c", script, "bash", program}, args...)
+}
+
+// apply sets the working directory, environment and credentials on cmd. It fails if
+// the niceness or limits cannot be applied on this platform.
+func (o ExecOptions) apply(cmd *exec.Cmd) error {
+    if err := checkNice(o.Nice); err != nil {
+        return err
+    }
+    if err := checkLimits(o.Limits); err != nil {
+        return err
+    }
+    cmd.Dir = o.Dir
+    cmd.Env = o.environ()
+    if o.User != nil {
+        return setCredential(cmd, o.User)
+    }
+    return nil
+}
+
+// environ builds the command environment, or nil to inherit the current one unchanged.
+func (o ExecOptions) environ() []string {
+    switch {
+    case o.CleanEnv:
+        return mergeEnv(nil, o.Env)
+    case o.EnvAllowlist != nil:
+        return mergeEnv(filterEnv(os.Environ(), o.EnvAllowlist), o.Env)
+    case o.Env != nil:
+        return mergeEnv(os.Environ(), o.Env)
+    }
+    return nil
+}
+
+// filterEnv keeps only the KEY=VALUE entries whose key is in allow.
+func filterEnv(env []string, allow []string) []string {
+    allowed := make(map[string]bool, len(allow))
+    for _, k := range allow {
+        allowed[k] = true
+    }
+
+    var kept []string
+    for _, kv := range env {
+        if k, _, ok := strings.Cut(kv, "="); ok && allowed[k] {
+            kept = append(kept, kv)
+        }
+    }
+    return kept
+}
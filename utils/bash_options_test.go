@@ -0,0 +1,89 @@
+package utils
+
+import (
+    "context"
+    "os"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+// TestExecOptionsLimits checks that rlimits are applied inside the command.
+func TestExecOptionsLimits(t *testing.T) {
+    skipWithoutBash(t)
+    opts := ExecOptions{Limits: ResourceLimits{OpenFiles: 64, CPUSeconds: 5, FileSizeBytes: 1 << 20}}
+    res, err := RunCommandWithOptions(context.Background(), "ulimit -n; ulimit -t; ulimit -f", opts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := strings.Fields(res.Stdout); strings.Join(got, ",") != "64,5,1024" {
+        t.Fatalf("got limits %v, want 64,5,1024", got)
+    }
+
+    // Writing past the file size limit must fail.
+    out := t.TempDir() + "/big"
+    opts = ExecOptions{Limits: ResourceLimits{FileSizeBytes: 1024}}
+    if _, err := RunCommandWithOptions(context.Background(), `head -c 8192 /dev/zero > "`+out+`"`, opts); err == nil {
+        t.Fatal("write past the file size limit succeeded")
+    }
+}
+
+// TestExecOptionsCleanEnv checks that CleanEnv drops the inherited environment and Env
+// is applied on top.
+func TestExecOptionsCleanEnv(t *testing.T) {
+    skipWithoutBash(t)
+    t.Setenv("UTILS_TEST_INHERITED", "yes")
+    opts := ExecOptions{CleanEnv: true, Env: map[string]string{"FOO": "bar"}}
+    res, err := RunCommandWithOptions(context.Background(), `echo "${UTILS_TEST_INHERITED:-unset} $FOO"`, opts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := strings.TrimSpace(res.Stdout); got != "unset bar" {
+        t.Fatalf("got %q, want %q", got, "unset bar")
+    }
+}
+
+// TestExecOptionsMaxOutputBytes checks that output beyond the cap is dropped and flagged.
+func TestExecOptionsMaxOutputBytes(t *testing.T) {
+    skipWithoutBash(t)
+    opts := ExecOptions{MaxOutputBytes: 100}
+    res, err := RunCommandWithOptions(context.Background(), `head -c 10000 /dev/zero | tr '\0' x`, opts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(res.Stdout) != 100 || !res.Truncated {
+        t.Fatalf("got %d bytes, truncated %v; want 100 bytes, truncated", len(res.Stdout), res.Truncated)
+    }
+}
+
+// TestExecOptionsNice checks that a lower priority is applied, and that a niceness that
+// cannot be set is an error rather than silently ignored.
+func TestExecOptionsNice(t *testing.T) {
+    skipWithoutBash(t)
+    current, err := RunCommand("nice")
+    if err != nil {
+        t.Fatal(err)
+    }
+    base, err := strconv.Atoi(current)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if base > 14 {
+        t.Skip("already running at a very low priority")
+    }
+
+    res, err := RunCommandWithOptions(context.Background(), "nice", ExecOptions{Nice: 5})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := strings.TrimSpace(res.Stdout); got != strconv.Itoa(base+5) {
+        t.Fatalf("got niceness %s, want %d", got, base+5)
+    }
+
+    if os.Geteuid() == 0 {
+        t.Skip("root may raise priority")
+    }
+    if _, err := RunCommandWithOptions(context.Background(), "true", ExecOptions{Nice: -5}); err == nil {
+        t.Fatal("raising priority without privileges succeeded silently")
+    }
+}
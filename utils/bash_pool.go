@@ -0,0 +1,122 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "runtime"
+    "sync"
+    "time"
+)
+
+// ErrCommandSkipped is reported for commands a fail-fast pool never started.
+var ErrCommandSkipped = errors.New("command skipped after earlier failure")
+
+// PoolOptions configures RunPool. Concurrency defaults to the number of CPUs and
+// a zero Timeout means commands run until the parent context is done.
+type PoolOptions struct {
+    Concurrency int
+    Timeout     time.Duration
+    FailFast    bool
+}
+
+// PoolResult is the outcome of one command in a pool, at the same index as its input.
+type PoolResult struct {
+    Index   int
+    Command string
+    Result  *CommandResult
+    Err     error
+}
+
+// RunPool runs commands with bounded parallelism and returns their results in input order.
+// With FailFast the first failure cancels running commands and skips the rest, and that
+// failure is returned; otherwise every command runs and all failures are joined.
+func RunPool(ctx context.Context, commands []*Command, opts PoolOptions) ([]PoolResult, error) {
+    concurrency := opts.Concurrency
+    if concurrency <= 0 {
+        concurrency = runtime.NumCPU()
+    }
+
+    poolCtx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    results := make([]PoolResult, len(commands))
+    sem := make(chan struct{}, concurrency)
+    var wg sync.WaitGroup
+    var once sync.Once
+    var firstErr error
+
+    for i, c := range commands {
+        results[i] = PoolResult{Index: i, Command: c.String()}
+
+        acquired := false
+        select {
+        case sem <- struct{}{}:
+            acquired = true
+        case <-poolCtx.Done():
+        }
+        if poolCtx.Err() != nil {
+            // Both cases may have been ready; never hold a slot for a command that is skipped.
+            if acquired {
+                <-sem
+            }
+            results[i].Err = skippedError(ctx)
+            continue
+        }
+
+        wg.Add(1)
+        go func(i int, c *Command) {
+            defer wg.Done()
+            defer func() { <-sem }()
+
+            cmdCtx := poolCtx
+            if opts.Timeout > 0 {
+                var cmdCancel context.CancelFunc
+                cmdCtx, cmdCancel = context.WithTimeout(poolCtx, opts.Timeout)
+                defer cmdCancel()
+            }
+
+            res, err := c.Run(cmdCtx)
+            results[i].Result = res
+            results[i].Err = err
+            if err != nil && opts.FailFast {
+                once.Do(func() {
+                    firstErr = err
+                    cancel()
+                })
+            }
+        }(i, c)
+    }
+    wg.Wait()
+
+    if opts.FailFast {
+        if firstErr == nil {
+            firstErr = ctx.Err()
+        }
+        return results, firstErr
+    }
+
+    var errs []error
+    for _, r := range results {
+        if r.Err != nil {
+            errs = append(errs, r.Err)
+        }
+    }
+    return results, errors.Join(errs...)
+}
+
+// RunTemplatePool runs the same Bash template once per value, passing each value as $1.
+func RunTemplatePool(ctx context.Context, script string, values []string, opts PoolOptions) ([]PoolResult, error) {
+    commands := make([]*Command, len(values))
+    for i, v := range values {
+        commands[i] = NewBashTemplate(script, v)
+    }
+    return RunPool(ctx, commands, opts)
+}
+
+// skippedError explains why a command was never started.
+func skippedError(parent context.Context) error {
+    if err := parent.Err(); err != nil {
+        return err
+    }
+    return ErrCommandSkipped
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+// TestRunPoolOrder checks that results come back in input order even when later
+// commands finish first.
+func TestRunPoolOrder(t *testing.T) {
+    skipWithoutBash(t)
+    values := []string{"3", "2", "1", "0"}
+    results, err := RunTemplatePool(context.Background(), `sleep "0.$1"; echo "$1"`, values, PoolOptions{Concurrency: 4})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i, r := range results {
+        if r.Index != i || strings.TrimSpace(r.Result.Stdout) != values[i] {
+            t.Fatalf("result %d: index %d, output %q; want %q", i, r.Index, r.Result.Stdout, values[i])
+        }
+    }
+}
+
+// TestRunPoolConcurrency checks that no more than Concurrency commands run at once.
+func TestRunPoolConcurrency(t *testing.T) {
+    skipWithoutBash(t)
+    dir := t.TempDir()
+    script := `touch "$1/$2"; sleep 0.2; ls "$1" | wc -l; rm "$1/$2"`
+
+    var commands []*Command
+    for i := 0; i < 6; i++ {
+        commands = append(commands, NewBashTemplate(script, dir, strconv.Itoa(i)))
+    }
+    start := time.Now()
+    results, err := RunPool(context.Background(), commands, PoolOptions{Concurrency: 2})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if elapsed := time.Since(start); elapsed < 600*time.Millisecond {
+        t.Fatalf("six 200ms commands two at a time took %v, want at least 600ms", elapsed)
+    }
+    for _, r := range results {
+        if n, _ := strconv.Atoi(strings.TrimSpace(r.Result.Stdout)); n < 1 || n > 2 {
+            t.Fatalf("command %d saw %d running commands, want at most 2", r.Index, n)
+        }
+    }
+}
+
+// TestRunPoolTimeout checks that Timeout applies to each command.
+func TestRunPoolTimeout(t *testing.T) {
+    skipWithoutBash(t)
+    commands := []*Command{NewCommand("bash", "-c", "sleep 10"), NewCommand("bash", "-c", "true")}
+
+    start := time.Now()
+    results, err := RunPool(context.Background(), commands, PoolOptions{Timeout: 100 * time.Millisecond})
+    if elapsed := time.Since(start); elapsed > 5*time.Second {
+        t.Fatalf("pool took %v", elapsed)
+    }
+    if !IsCommandTimeout(results[0].Err) || !IsCommandTimeout(err) {
+        t.Fatalf("got %v (joined %v), want a timeout", results[0].Err, err)
+    }
+    if results[1].Err != nil {
+        t.Fatalf("fast command failed: %v", results[1].Err)
+    }
+}
+
+// TestRunPoolFailFast checks that the first failure cancels running commands, skips the
+// rest and is returned.
+func TestRunPoolFailFast(t *testing.T) {
+    skipWithoutBash(t)
+    commands := []*Command{
+        NewCommand("bash", "-c", "sleep 0.1; exit 7"),
+        NewCommand("bash", "-c", "sleep 10"),
+        NewCommand("bash", "-c", "true"),
+        NewCommand("bash", "-c", "true"),
+    }
+
+    start := time.Now()
+    results, err := RunPool(context.Background(), commands, PoolOptions{Concurrency: 2, FailFast: true})
+    if elapsed := time.Since(start); elapsed > 5*time.Second {
+        t.Fatalf("pool took %v, running commands were not cancelled", elapsed)
+    }
+    var cmdErr *CommandError
+    if !errors.As(err, &cmdErr) || cmdErr.ExitCode != 7 {
+        t.Fatalf("got %v, want the exit 7 failure", err)
+    }
+    if !IsCommandCancelled(results[1].Err) {
+        t.Fatalf("running command: got %v, want cancelled", results[1].Err)
+    }
+    for _, r := range results[2:] {
+        if !errors.Is(r.Err, ErrCommandSkipped) || r.Result != nil {
+            t.Fatalf("command %d: got %v, want skipped", r.Index, r.Err)
+        }
+    }
+}
+
+// TestRunPoolCollectAll checks that without FailFast every command runs and all
+// failures are reported.
+func TestRunPoolCollectAll(t *testing.T) {
+    skipWithoutBash(t)
+    commands := []*Command{
+        NewCommand("bash", "-c", "exit 1"),
+        NewCommand("bash", "-c", "echo ok"),
+        NewCommand("bash", "-c", "exit 2"),
+    }
+
+    results, err := RunPool(context.Background(), commands, PoolOptions{Concurrency: 1})
+    if err == nil {
+        t.Fatal("got nil error, want the joined failures")
+    }
+    for _, i := range []int{0, 2} {
+        if !errors.Is(err, results[i].Err) {
+            t.Fatalf("joined error %v does not include command %d", err, i)
+        }
+    }
+    if results[1].Err != nil || strings.TrimSpace(results[1].Result.Stdout) != "ok" {
+        t.Fatalf("middle command: %v, %q", results[1].Err, results[1].Result.Stdout)
+    }
+}
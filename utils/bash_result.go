@@ -0,0 +1,136 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "io"
+    "os/exec"
+    "strings"
+    "sync"
+    "time"
+)
+
+// CommandResult holds everything known about a finished command.
+// Output fields are byte-exact; use Trimmed for whitespace-trimmed copies. Stdout and
+// Stderr are read from separate pipes, so the order of lines across the two streams
+// in Combined is approximate.
+type CommandResult struct {
+    Command   string
+    Stdout    string
+    Stderr    string
+    Combined  string
+    ExitCode  int
+    PID       int
+    StartedAt time.Time
+    EndedAt   time.Time
+    Duration  time.Duration
+    Signalled bool
+    Signal    string
+    Truncated bool
+}
+
+// Success reports whether the command exited with status 0.
+func (r *CommandResult) Success() bool {
+    return r.ExitCode == 0
+}
+
+// Trimmed returns a copy of the result with leading and trailing whitespace removed from all output.
+func (r *CommandResult) Trimmed() *CommandResult {
+    c := *r
+    c.Stdout = strings.TrimSpace(r.Stdout)
+    c.Stderr = strings.TrimSpace(r.Stderr)
+    c.Combined = strings.TrimSpace(r.Combined)
+    return &c
+}
+
+// runResult runs cmd, capturing its output, and returns a CommandResult.
+// When split is false and cmd has no Stdout/Stderr writers, both streams share one
+// writer so exec gives the child a single pipe and Combined keeps the exact order
+// the command wrote in; Stdout and Stderr are left empty. Otherwise the streams are
+// captured separately and, as they arrive on different pipes, Combined only
+// approximates their interleaving. Any writers already set on cmd also receive the
+// output. The output cap from opts is applied here; the rest of opts must already
+// be applied to cmd.
+func runResult(ctx context.Context, cmd *exec.Cmd, label string, opts ExecOptions, split bool) (*CommandResult, error) {
+    outBuf := &cappedBuffer{limit: opts.MaxOutputBytes}
+    errBuf := &cappedBuffer{limit: opts.MaxOutputBytes}
+    combined := &cappedBuffer{limit: opts.MaxOutputBytes}
+
+    if !split && cmd.Stdout == nil && cmd.Stderr == nil {
+        cmd.Stdout = combined
+        cmd.Stderr = combined
+    } else {
+        cmd.Stdout = teeWriter(cmd.Stdout, outBuf, combined)
+        cmd.Stderr = teeWriter(cmd.Stderr, errBuf, combined)
+    }
+
+    res := &CommandResult{Command: label, ExitCode: -1, StartedAt: time.Now()}
+    err := cmd.Start()
+    if err == nil {
+        res.PID = cmd.Process.Pid
+        err = cmd.Wait()
+    }
+    res.EndedAt = time.Now()
+    res.Duration = res.EndedAt.Sub(res.StartedAt)
+
+    if cmd.ProcessState != nil {
+        res.ExitCode = cmd.ProcessState.ExitCode()
+        res.Signalled, res.Signal = processSignal(cmd.ProcessState)
+    }
+    res.Stdout = outBuf.String()
+    res.Stderr = errBuf.String()
+    res.Combined = combined.String()
+    res.Truncated = outBuf.Truncated() || errBuf.Truncated() || combined.Truncated()
+
+    return res, classifyCommandError(ctx, err, label)
+}
+
+// teeWriter writes to buf and combined, plus extra when it is non-nil.
+func teeWriter(extra io.Writer, buf io.Writer, combined io.Writer) io.Writer {
+    if extra == nil {
+        return io.MultiWriter(buf, combined)
+    }
+    return io.MultiWriter(buf, combined, extra)
+}
+
+// cappedBuffer is a bytes.Buffer safe for concurrent writes from the stdout and stderr copiers.
+// Once limit bytes are held (when limit > 0) further output is discarded and the buffer is
+// marked truncated; writes still succeed so the command is not killed by a broken pipe.
+type cappedBuffer struct {
+    mu        sync.Mutex
+    buf       bytes.Buffer
+    limit     int64
+    truncated bool
+}
+
+// Write appends as much of p to the buffer as the limit allows.
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    if b.limit > 0 {
+        room := b.limit - int64(b.buf.Len())
+        if int64(len(p)) > room {
+            b.truncated = true
+            if room > 0 {
+                b.buf.Write(p[:room])
+            }
+            return len(p), nil
+        }
+    }
+    return b.buf.Write(p)
+}
+
+// String returns the buffered contents.
+func (b *cappedBuffer) String() string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.buf.String()
+}
+
+// Truncated reports whether any output was discarded.
+func (b *cappedBuffer) Truncated() bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.truncated
+}
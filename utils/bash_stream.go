@@ -0,0 +1,181 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "io"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// OutputStream identifies which output stream a line came from.
+type OutputStream string
+
+const (
+    StreamStdout OutputStream = "stdout"
+    StreamStderr OutputStream = "stderr"
+)
+
+// OutputLine is a single line of command output with ordering metadata.
+// Seq increases monotonically across both streams in the order lines were received.
+type OutputLine struct {
+    Seq    uint64
+    Stream OutputStream
+    Time   time.Time
+    Text   string
+}
+
+// StreamOptions configures a streaming command run.
+// OnLine is called synchronously for every line; Lines, if set, receives every line
+// and is closed when the command finishes, so the caller must keep draining it until
+// ctx is done; after that, lines the caller does not read are dropped.
+type StreamOptions struct {
+    Stdin  io.Reader
+    Env    map[string]string
+    OnLine func(OutputLine)
+    Lines  chan<- OutputLine
+}
+
+// StreamCommand runs an inline Bash command under ctx, delivering stdout and stderr
+// line-by-line as they are produced. Returns the exit code once the command finishes.
+func StreamCommand(ctx context.Context, command string, opts StreamOptions) (int, error) {
+    return streamBash(ctx, command, opts, "-c", command)
+}
+
+// StreamScript runs a Bash script file under ctx, delivering output line-by-line.
+// Returns the exit code once the script finishes.
+func StreamScript(ctx context.Context, scriptPath string, args []string, opts StreamOptions) (int, error) {
+    return streamBash(ctx, scriptPath, opts, scriptArgs(scriptPath, args)...)
+}
+
+// StreamToLogger returns an OnLine callback that forwards stdout lines as INFO
+// and stderr lines as WARN to the given logger.
+func StreamToLogger(logger *Logger) func(OutputLine) {
+    return func(line OutputLine) {
+        if line.Stream == StreamStderr {
+            logger.Warnf("%s", line.Text)
+            return
+        }
+        logger.Infof("%s", line.Text)
+    }
+}
+
+// StreamScriptToLogger runs a Bash script and forwards its output live to a logger
+// whose LOG_SUBJECT is the script's file name.
+func StreamScriptToLogger(ctx context.Context, namespace, scriptPath string, args ...string) (int, error) {
+    logger := NewLogger(namespace, filepath.Base(scriptPath))
+    return StreamScript(ctx, scriptPath, args, StreamOptions{OnLine: StreamToLogger(logger)})
+}
+
+// streamBash runs bash with args, wiring stdout and stderr through line emitters.
+func streamBash(ctx context.Context, label string, opts StreamOptions, args ...string) (int, error) {
+    em := &lineEmitter{done: ctx.Done(), onLine: opts.OnLine, lines: opts.Lines}
+    stdout := &lineWriter{stream: StreamStdout, emitter: em}
+    stderr := &lineWriter{stream: StreamStderr, emitter: em}
+
+    cmd := newBashCommand(ctx, args...)
+    cmd.Stdin = opts.Stdin
+    cmd.Stdout = stdout
+    cmd.Stderr = stderr
+    if opts.Env != nil {
+        cmd.Env = mergeEnv(os.Environ(), opts.Env)
+    }
+
+    err := runCommand(ctx, cmd, label)
+    stdout.flush()
+    stderr.flush()
+    em.close()
+
+    return exitCodeOf(cmd), err
+}
+
+// exitCodeOf returns the exit code of a finished command, or -1 if it never ran to completion.
+func exitCodeOf(cmd *exec.Cmd) int {
+    if cmd.ProcessState == nil {
+        return -1
+    }
+    return cmd.ProcessState.ExitCode()
+}
+
+// lineEmitter serialises lines from both streams and stamps them with ordering metadata.
+// Once done is closed, lines the channel reader is not ready for are dropped.
+type lineEmitter struct {
+    mu     sync.Mutex
+    seq    uint64
+    done   <-chan struct{}
+    closed bool
+    onLine func(OutputLine)
+    lines  chan<- OutputLine
+}
+
+// emit delivers a single line to the configured callback and channel.
+func (e *lineEmitter) emit(stream OutputStream, text string) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    if e.closed {
+        return
+    }
+    e.seq++
+    line := OutputLine{Seq: e.seq, Stream: stream, Time: time.Now(), Text: text}
+    if e.onLine != nil {
+        e.onLine(line)
+    }
+    if e.lines != nil {
+        select {
+        case e.lines <- line:
+        case <-e.done:
+        }
+    }
+}
+
+// close closes the lines channel once any send in progress has finished. Output that
+// arrives later, from a background child still holding the pipes, is discarded.
+func (e *lineEmitter) close() {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    e.closed = true
+    if e.lines != nil {
+        close(e.lines)
+    }
+}
+
+// lineWriter is an io.Writer that splits written bytes into lines for a lineEmitter.
+type lineWriter struct {
+    mu      sync.Mutex
+    stream  OutputStream
+    emitter *lineEmitter
+    buf     []byte
+}
+
+// Write buffers p and emits every complete line it contains.
+func (w *lineWriter) Write(p []byte) (int, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    w.buf = append(w.buf, p...)
+    for {
+        i := bytes.IndexByte(w.buf, '\n')
+        if i < 0 {
+            break
+        }
+        w.emitter.emit(w.stream, string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+        w.buf = w.buf[i+1:]
+    }
+    return len(p), nil
+}
+
+// flush emits any trailing output that was not newline-terminated.
+func (w *lineWriter) flush() {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if len(w.buf) > 0 {
+        w.emitter.emit(w.stream, string(bytes.TrimSuffix(w.buf, []byte("\r"))))
+        w.buf = nil
+    }
+}
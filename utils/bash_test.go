@@ -0,0 +1,56 @@
+package utils
+
+import (
+    "context"
+    "fmt"
+    "runtime"
+    "strings"
+    "testing"
+)
+
+// TestRunCommandCombinedOrder checks that the combined-output helpers keep the order
+// in which a command alternates between stdout and stderr.
+func TestRunCommandCombinedOrder(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("needs bash")
+    }
+
+    script := `for i in 1 2 3 4 5 6 7 8 9 10; do echo o$i; echo e$i >&2; done`
+    var want []string
+    for i := 1; i <= 10; i++ {
+        want = append(want, fmt.Sprintf("o%d", i), fmt.Sprintf("e%d", i))
+    }
+
+    for run := 0; run < 20; run++ {
+        out, err := RunCommand(script)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := strings.Split(out, "\n"); strings.Join(got, ",") != strings.Join(want, ",") {
+            t.Fatalf("RunCommand run %d: got %q, want %q", run, got, want)
+        }
+
+        out, err = NewCommand("bash", "-c", script).Output(context.Background())
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := strings.Split(out, "\n"); strings.Join(got, ",") != strings.Join(want, ",") {
+            t.Fatalf("Command.Output run %d: got %q, want %q", run, got, want)
+        }
+    }
+}
+
+// TestRunAndCaptureSplitsStreams checks that asking for separate streams still works.
+func TestRunAndCaptureSplitsStreams(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("needs bash")
+    }
+
+    stdout, stderr, err := RunAndCapture(`echo out; echo err >&2`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if stdout != "out" || stderr != "err" {
+        t.Fatalf("got stdout %q, stderr %q", stdout, stderr)
+    }
+}
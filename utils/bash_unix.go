@@ -0,0 +1,83 @@
+//go:build !windows
+
+package utils
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "syscall"
+    "time"
+)
+
+// processWaitDelay bounds how long Wait blocks on output pipes after the process group is killed.
+const processWaitDelay = 2 * time.Second
+
+// setProcessGroup places cmd in its own process group and makes context cancellation
+// kill the entire group rather than only the bash process.
+func setProcessGroup(cmd *exec.Cmd) {
+    if cmd.SysProcAttr == nil {
+        cmd.SysProcAttr = &syscall.SysProcAttr{}
+    }
+    cmd.SysProcAttr.Setpgid = true
+    cmd.Cancel = func() error {
+        return killProcessGroup(cmd, syscall.SIGKILL)
+    }
+    cmd.WaitDelay = processWaitDelay
+}
+
+// killProcessGroup sends sig to the process group led by cmd's process.
+func killProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+    if cmd.Process == nil {
+        return nil
+    }
+    return syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+// signalProcess asks the process group to stop with SIGTERM, or kills it with SIGKILL when force is set.
+func signalProcess(cmd *exec.Cmd, force bool) error {
+    if force {
+        return killProcessGroup(cmd, syscall.SIGKILL)
+    }
+    return killProcessGroup(cmd, syscall.SIGTERM)
+}
+
+// setCredential runs cmd as the given user and group.
+func setCredential(cmd *exec.Cmd, user *ExecUser) error {
+    cmd.SysProcAttr.Credential = &syscall.Credential{Uid: user.UID, Gid: user.GID}
+    return nil
+}
+
+// checkNice verifies that nice(1), which ExecOptions.wrap runs the command under, can
+// apply the niceness. nice only warns and carries on at the normal priority when it
+// cannot, for example when raising priority without privileges, so a trial run that
+// prints anything or fails is reported as an error instead.
+func checkNice(nice int) error {
+    if nice == 0 {
+        return nil
+    }
+    out, err := exec.Command("nice", "-n", strconv.Itoa(nice), "--", "true").CombinedOutput()
+    if msg := strings.TrimSpace(string(out)); msg != "" {
+        return fmt.Errorf("cannot set niceness %d: %s", nice, msg)
+    }
+    if err != nil {
+        return fmt.Errorf("cannot set niceness %d: %w", nice, err)
+    }
+    return nil
+}
+
+// checkLimits accepts any limits; ExecOptions.wrap applies them with bash ulimit.
+func checkLimits(limits ResourceLimits) error {
+    return nil
+}
+
+// processSignal reports whether the process was terminated by a signal, and which one.
+func processSignal(state *os.ProcessState) (bool, string) {
+    status, ok := state.Sys().(syscall.WaitStatus)
+    if !ok || !status.Signaled() {
+        return false, ""
+    }
+    return true, status.Signal().String()
+}
@@ -0,0 +1,52 @@
+//go:build windows
+
+package utils
+
+import (
+    "errors"
+    "os"
+    "os/exec"
+    "time"
+)
+
+// processWaitDelay bounds how long Wait blocks on output pipes after the process is killed.
+const processWaitDelay = 2 * time.Second
+
+// setProcessGroup is a best effort on Windows: cancellation kills the bash process only.
+func setProcessGroup(cmd *exec.Cmd) {
+    cmd.WaitDelay = processWaitDelay
+}
+
+// signalProcess kills the process; Windows has no SIGTERM equivalent for console processes.
+func signalProcess(cmd *exec.Cmd, force bool) error {
+    if cmd.Process == nil {
+        return nil
+    }
+    return cmd.Process.Kill()
+}
+
+// setCredential is not supported on Windows.
+func setCredential(cmd *exec.Cmd, user *ExecUser) error {
+    return errors.New("switching user is not supported on windows")
+}
+
+// checkNice rejects a non-zero niceness, which is not supported on Windows.
+func checkNice(nice int) error {
+    if nice != 0 {
+        return errors.New("setting niceness is not supported on windows")
+    }
+    return nil
+}
+
+// checkLimits rejects resource limits, which are not supported on Windows.
+func checkLimits(limits ResourceLimits) error {
+    if !limits.isZero() {
+        return errors.New("resource limits are not supported on windows")
+    }
+    return nil
+}
+
+// processSignal always reports false on Windows, where processes are not terminated by signals.
+func processSignal(state *os.ProcessState) (bool, string) {
+    return false, ""
+}
@@ -3,9 +3,12 @@ package utils
 import (
     "bufio"
     "errors"
+    "io"
     "io/fs"
     "os"
+    "path"
     "path/filepath"
+    "strings"
 )
 
 // ReadFile reads the contents of a file and returns it as a string.
@@ -18,6 +21,8 @@ func ReadFile(path string) (string, error) {
 }
 
 // ReadLines reads a file line-by-line and returns a slice of strings.
+// Lines have their trailing "\n" or "\r\n" removed and may be of any length; use
+// ReadLinesFunc to stream large or gzip-compressed files.
 func ReadLines(path string) ([]string, error) {
     file, err := os.Open(path)
     if err != nil {
@@ -25,16 +30,26 @@ func ReadLines(path string) ([]string, error) {
     }
     defer file.Close()
 
-    var lines []string
-    scanner := bufio.NewScanner(file)
-    for scanner.Scan() {
-        lines = append(lines, scanner.Text())
-    }
+    return readLines(file)
+}
 
-    if err := scanner.Err(); err != nil {
-        return nil, err
+// readLines reads every line of r, without its line ending, into a slice.
+func readLines(r io.Reader) ([]string, error) {
+    var lines []string
+    reader := bufio.NewReader(r)
+    for {
+        line, err := reader.ReadString('\n')
+        if line != "" {
+            line = strings.TrimSuffix(line, "\n")
+            lines = append(lines, strings.TrimSuffix(line, "\r"))
+        }
+        if err == io.EOF {
+            return lines, nil
+        }
+        if err != nil {
+            return nil, err
+        }
     }
-    return lines, nil
 }
 
 // WriteFile writes the given content to a file, overwriting if it exists.
@@ -62,9 +77,60 @@ func FileExists(path string) bool {
 
 // ListFiles returns a list of file names (not directories) in the given folder.
 func ListFiles(dir string) ([]string, error) {
+    return ListFilesFS(NewOSFS(dir), ".")
+}
+
+// FindFilesByExtension recursively finds files with a given extension.
+// The returned paths start with root.
+func FindFilesByExtension(root, ext string) ([]string, error) {
+    names, err := FindFilesByExtensionFS(NewOSFS(root), ".", ext)
+    for i, name := range names {
+        names[i] = filepath.Join(root, filepath.FromSlash(name))
+    }
+    return names, err
+}
+
+// ReadFileFS reads a file from any fs.FS, such as an embed.FS or a WritableFS, as a string.
+func ReadFileFS(fsys fs.FS, name string) (string, error) {
+    data, err := fs.ReadFile(fsys, name)
+    if err != nil {
+        return "", err
+    }
+    return string(data), nil
+}
+
+// ReadLinesFS reads a file from fsys line-by-line, like ReadLines.
+func ReadLinesFS(fsys fs.FS, name string) ([]string, error) {
+    f, err := fsys.Open(name)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    return readLines(f)
+}
+
+// WriteFileFS writes content to a file in fsys, overwriting it if it exists.
+func WriteFileFS(fsys WritableFS, name string, content string) error {
+    return writeFS(fsys, name, content, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+}
+
+// AppendToFileFS appends content to a file in fsys, creating it if it doesn't exist.
+func AppendToFileFS(fsys WritableFS, name string, content string) error {
+    return writeFS(fsys, name, content, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+}
+
+// FileExistsFS checks if a file or directory exists in fsys.
+func FileExistsFS(fsys fs.FS, name string) bool {
+    _, err := fs.Stat(fsys, name)
+    return !errors.Is(err, fs.ErrNotExist)
+}
+
+// ListFilesFS returns the names of the files (not directories) in a directory of fsys.
+func ListFilesFS(fsys fs.FS, dir string) ([]string, error) {
     var files []string
 
-    entries, err := os.ReadDir(dir)
+    entries, err := fs.ReadDir(fsys, dir)
     if err != nil {
         return nil, err
     }
@@ -78,19 +144,33 @@ func ListFiles(dir string) ([]string, error) {
     return files, nil
 }
 
-// FindFilesByExtension recursively finds files with a given extension.
-func FindFilesByExtension(root, ext string) ([]string, error) {
+// FindFilesByExtensionFS recursively finds files with a given extension in fsys.
+func FindFilesByExtensionFS(fsys fs.FS, root, ext string) ([]string, error) {
     var matched []string
 
-    err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+    err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
         if err != nil {
             return err
         }
-        if !d.IsDir() && filepath.Ext(path) == ext {
-            matched = append(matched, path)
+        if !d.IsDir() && path.Ext(d.Name()) == ext {
+            matched = append(matched, name)
         }
         return nil
     })
 
     return matched, err
 }
+
+// writeFS opens name in fsys with flag and writes content to it.
+func writeFS(fsys WritableFS, name string, content string, flag int) error {
+    f, err := fsys.OpenFile(name, flag, 0644)
+    if err != nil {
+        return err
+    }
+
+    if _, err := io.WriteString(f, content); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
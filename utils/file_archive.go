@@ -0,0 +1,655 @@
+package utils
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// ArchiveFormat selects the container used by the archive helpers.
+type ArchiveFormat string
+
+const (
+    ArchiveTar   ArchiveFormat = "tar"
+    ArchiveTarGz ArchiveFormat = "tar.gz"
+    ArchiveZip   ArchiveFormat = "zip"
+)
+
+// DefaultMaxExtractSize caps the bytes written by an extraction when ArchiveOptions leaves it unset.
+const DefaultMaxExtractSize = 1 << 30
+
+// ErrArchiveTooLarge is returned when extraction would exceed the size limit.
+var ErrArchiveTooLarge = errors.New("archive exceeds maximum extracted size")
+
+// ArchiveOptions configures packing and extraction.
+//
+// Include and Exclude are filepath.Match globs tested against both the base name and the
+// slash-separated path inside the archive; an empty Include matches every file and Exclude
+// always wins, also for everything below an excluded directory. MaxSize limits the total
+// number of bytes extracted; a negative value disables the limit.
+type ArchiveOptions struct {
+    Include []string
+    Exclude []string
+    MaxSize int64
+}
+
+// DetectArchiveFormat infers the format from a file name (.tar, .tar.gz, .tgz or .zip).
+func DetectArchiveFormat(name string) (ArchiveFormat, error) {
+    lower := strings.ToLower(name)
+    switch {
+    case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+        return ArchiveTarGz, nil
+    case strings.HasSuffix(lower, ".tar"):
+        return ArchiveTar, nil
+    case strings.HasSuffix(lower, ".zip"):
+        return ArchiveZip, nil
+    }
+    return "", fmt.Errorf("unknown archive format: %s", name)
+}
+
+// CreateArchive packs srcDir into the archive file dst, inferring the format from its name.
+func CreateArchive(dst, srcDir string, opts ArchiveOptions) error {
+    format, err := DetectArchiveFormat(dst)
+    if err != nil {
+        return err
+    }
+
+    f, err := os.Create(dst)
+    if err != nil {
+        return err
+    }
+    if err := WriteArchive(f, srcDir, format, opts); err != nil {
+        f.Close()
+        os.Remove(dst)
+        return err
+    }
+    return f.Close()
+}
+
+// WriteArchive streams the contents of srcDir to w. Paths are stored relative to srcDir,
+// and file modes and symlinks are preserved.
+func WriteArchive(w io.Writer, srcDir string, format ArchiveFormat, opts ArchiveOptions) error {
+    var aw archiveWriter
+    switch format {
+    case ArchiveTar:
+        aw = &tarArchiveWriter{tw: tar.NewWriter(w)}
+    case ArchiveTarGz:
+        zw := gzip.NewWriter(w)
+        aw = &tarArchiveWriter{tw: tar.NewWriter(zw), gz: zw}
+    case ArchiveZip:
+        aw = &zipArchiveWriter{zw: zip.NewWriter(w)}
+    default:
+        return fmt.Errorf("unsupported archive format %q", format)
+    }
+
+    err := filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if p == srcDir {
+            return nil
+        }
+        rel, err := filepath.Rel(srcDir, p)
+        if err != nil {
+            return err
+        }
+        name := filepath.ToSlash(rel)
+
+        if archiveSkipped(opts, name, d.IsDir()) {
+            if d.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+        return aw.add(p, name, info)
+    })
+    if err != nil {
+        aw.close()
+        return err
+    }
+    return aw.close()
+}
+
+// ExtractArchive unpacks the archive file src into dstDir, inferring the format from its name.
+func ExtractArchive(src, dstDir string, opts ArchiveOptions) error {
+    format, err := DetectArchiveFormat(src)
+    if err != nil {
+        return err
+    }
+
+    f, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    if format == ArchiveZip {
+        info, err := f.Stat()
+        if err != nil {
+            return err
+        }
+        return extractZip(f, info.Size(), dstDir, opts)
+    }
+    return ReadArchive(f, format, dstDir, opts)
+}
+
+// ReadArchive unpacks an archive stream into dstDir. Entries that would land outside
+// dstDir, directly or through a symlink, are rejected. Zip input is spooled to a
+// temporary file next to dstDir because the format needs random access; a zip stream
+// larger than MaxSize fails with ErrArchiveTooLarge before anything is extracted.
+func ReadArchive(r io.Reader, format ArchiveFormat, dstDir string, opts ArchiveOptions) error {
+    switch format {
+    case ArchiveTar:
+        return extractTar(r, dstDir, opts)
+    case ArchiveTarGz:
+        zr, err := gzip.NewReader(r)
+        if err != nil {
+            return err
+        }
+        defer zr.Close()
+        return extractTar(zr, dstDir, opts)
+    case ArchiveZip:
+        limit := opts.MaxSize
+        if limit == 0 {
+            limit = DefaultMaxExtractSize
+        }
+        parent := filepath.Dir(filepath.Clean(dstDir))
+        if err := os.MkdirAll(parent, 0755); err != nil {
+            return err
+        }
+        tmp, err := os.CreateTemp(parent, ".archive-*.zip")
+        if err != nil {
+            return err
+        }
+        defer os.Remove(tmp.Name())
+        defer tmp.Close()
+
+        src := r
+        if limit > 0 {
+            src = io.LimitReader(r, limit+1)
+        }
+        size, err := io.Copy(tmp, src)
+        if err != nil {
+            return err
+        }
+        if limit > 0 && size > limit {
+            return ErrArchiveTooLarge
+        }
+        return extractZip(tmp, size, dstDir, opts)
+    }
+    return fmt.Errorf("unsupported archive format %q", format)
+}
+
+// archiveSkipped applies the include and exclude filters to an archive path.
+func archiveSkipped(opts ArchiveOptions, name string, isDir bool) bool {
+    for dir := name; dir != "." && dir != "/"; dir = path.Dir(dir) {
+        if matchAnyGlob(".", dir, opts.Exclude) {
+            return true
+        }
+    }
+    return !isDir && len(opts.Include) > 0 && !matchAnyGlob(".", name, opts.Include)
+}
+
+// archiveWriter adds filesystem entries to an archive.
+type archiveWriter interface {
+    add(srcPath, name string, info fs.FileInfo) error
+    close() error
+}
+
+// tarArchiveWriter writes tar entries, optionally through gzip.
+type tarArchiveWriter struct {
+    tw *tar.Writer
+    gz *gzip.Writer
+}
+
+// add writes one entry and, for regular files, its content.
+func (a *tarArchiveWriter) add(srcPath, name string, info fs.FileInfo) error {
+    var link string
+    if info.Mode()&fs.ModeSymlink != 0 {
+        target, err := os.Readlink(srcPath)
+        if err != nil {
+            return err
+        }
+        link = target
+    }
+
+    hdr, err := tar.FileInfoHeader(info, link)
+    if err != nil {
+        return err
+    }
+    hdr.Name = name
+    if info.IsDir() {
+        hdr.Name += "/"
+    }
+    if err := a.tw.WriteHeader(hdr); err != nil {
+        return err
+    }
+    if !info.Mode().IsRegular() {
+        return nil
+    }
+    return copyFileTo(a.tw, srcPath)
+}
+
+// close finishes the tar stream and the gzip layer, if any.
+func (a *tarArchiveWriter) close() error {
+    err := a.tw.Close()
+    if a.gz != nil {
+        if gzErr := a.gz.Close(); err == nil {
+            err = gzErr
+        }
+    }
+    return err
+}
+
+// zipArchiveWriter writes zip entries; symlinks are stored with their target as content.
+type zipArchiveWriter struct {
+    zw *zip.Writer
+}
+
+// add writes one entry and its content.
+func (a *zipArchiveWriter) add(srcPath, name string, info fs.FileInfo) error {
+    hdr, err := zip.FileInfoHeader(info)
+    if err != nil {
+        return err
+    }
+    hdr.Name = name
+    if info.IsDir() {
+        hdr.Name += "/"
+    } else if info.Mode().IsRegular() {
+        hdr.Method = zip.Deflate
+    }
+
+    w, err := a.zw.CreateHeader(hdr)
+    if err != nil {
+        return err
+    }
+    switch {
+    case info.Mode()&fs.ModeSymlink != 0:
+        target, err := os.Readlink(srcPath)
+        if err != nil {
+            return err
+        }
+        _, err = io.WriteString(w, target)
+        return err
+    case info.Mode().IsRegular():
+        return copyFileTo(w, srcPath)
+    }
+    return nil
+}
+
+// close writes the zip central directory.
+func (a *zipArchiveWriter) close() error {
+    return a.zw.Close()
+}
+
+// copyFileTo streams the content of a file into w.
+func copyFileTo(w io.Writer, path string) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    _, err = io.Copy(w, f)
+    return err
+}
+
+// extractor writes archive entries below a root directory, enforcing path safety and the size limit.
+type extractor struct {
+    root      string
+    realRoot  string
+    opts      ArchiveOptions
+    remaining int64
+    links     []string
+}
+
+// newExtractor creates dstDir and prepares an extractor for it.
+func newExtractor(dstDir string, opts ArchiveOptions) (*extractor, error) {
+    if err := os.MkdirAll(dstDir, 0755); err != nil {
+        return nil, err
+    }
+    realRoot, err := filepath.EvalSymlinks(dstDir)
+    if err != nil {
+        return nil, err
+    }
+    if opts.MaxSize == 0 {
+        opts.MaxSize = DefaultMaxExtractSize
+    }
+    return &extractor{root: filepath.Clean(dstDir), realRoot: realRoot, opts: opts, remaining: opts.MaxSize}, nil
+}
+
+// target maps an archive name to a path below the root, rejecting absolute names and "..".
+// The root entry itself ("." or "./") maps to an empty string and is skipped by callers.
+func (e *extractor) target(name string) (string, error) {
+    name = strings.ReplaceAll(name, "\\", "/")
+    if path.IsAbs(name) {
+        return "", fmt.Errorf("archive entry %q has an absolute path", name)
+    }
+    for _, part := range strings.Split(name, "/") {
+        if part == ".." {
+            return "", fmt.Errorf("archive entry %q escapes the destination", name)
+        }
+    }
+    clean := path.Clean(name)
+    if clean == "." {
+        return "", nil
+    }
+    return filepath.Join(e.root, filepath.FromSlash(clean)), nil
+}
+
+// within reports whether p lies inside root.
+func within(root, p string) bool {
+    rel, err := filepath.Rel(root, p)
+    return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// prepare creates the missing parents of target one component at a time, checking that
+// every existing component resolves inside the root before anything is created below it,
+// so that a previously extracted symlink cannot redirect the write elsewhere.
+func (e *extractor) prepare(target string) error {
+    rel, err := filepath.Rel(e.root, filepath.Dir(target))
+    if err != nil {
+        return err
+    }
+    dir := e.root
+    if rel != "." {
+        for _, part := range strings.Split(rel, string(filepath.Separator)) {
+            dir = filepath.Join(dir, part)
+            if _, err := os.Lstat(dir); errors.Is(err, fs.ErrNotExist) {
+                if err := os.Mkdir(dir, 0755); err != nil {
+                    return err
+                }
+                continue
+            } else if err != nil {
+                return err
+            }
+            real, err := filepath.EvalSymlinks(dir)
+            if err != nil {
+                return err
+            }
+            if !within(e.realRoot, real) {
+                return fmt.Errorf("archive entry %s escapes the destination through a symlink", target)
+            }
+        }
+    }
+    if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+        return os.Remove(target)
+    }
+    return nil
+}
+
+// resolveLink follows linkname from the real directory dir one component at a time,
+// resolving symlinks on disk, and reports whether it stays inside the root. Components
+// that do not exist yet are joined lexically, but may not be followed by "..", since a
+// later entry could turn them into a symlink.
+func (e *extractor) resolveLink(dir, linkname string) bool {
+    cur := dir
+    missing := false
+    for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+        switch part {
+        case "", ".":
+            continue
+        case "..":
+            if missing {
+                return false
+            }
+            cur = filepath.Dir(cur)
+        default:
+            next := filepath.Join(cur, part)
+            info, err := os.Lstat(next)
+            switch {
+            case err != nil:
+                missing = true
+                cur = next
+            case info.Mode()&fs.ModeSymlink != 0:
+                real, err := filepath.EvalSymlinks(next)
+                if err != nil {
+                    return false
+                }
+                cur = real
+            default:
+                cur = next
+            }
+        }
+        if !within(e.realRoot, cur) {
+            return false
+        }
+    }
+    return true
+}
+
+// finish re-checks every extracted symlink against the final tree, because a later entry
+// may have replaced a symlink an earlier link was resolved through. Links that now point
+// outside the root are removed. err is the extraction error, which takes precedence.
+func (e *extractor) finish(err error) error {
+    var linkErr error
+    for _, link := range e.links {
+        info, lerr := os.Lstat(link)
+        if lerr != nil || info.Mode()&fs.ModeSymlink == 0 {
+            continue
+        }
+        linkname, lerr := os.Readlink(link)
+        if lerr == nil {
+            var parent string
+            if parent, lerr = filepath.EvalSymlinks(filepath.Dir(link)); lerr == nil && e.resolveLink(parent, linkname) {
+                continue
+            }
+        }
+        os.Remove(link)
+        if linkErr == nil {
+            linkErr = fmt.Errorf("archive symlink %s points outside the destination", link)
+        }
+    }
+    if err != nil {
+        return err
+    }
+    return linkErr
+}
+
+// dir creates a directory entry.
+func (e *extractor) dir(target string, mode fs.FileMode) error {
+    if err := e.prepare(target); err != nil {
+        return err
+    }
+    if err := os.MkdirAll(target, 0755); err != nil {
+        return err
+    }
+    return os.Chmod(target, mode.Perm()|0700)
+}
+
+// file writes a regular file entry, counting its bytes against the size limit.
+func (e *extractor) file(target string, mode fs.FileMode, r io.Reader) error {
+    if err := e.prepare(target); err != nil {
+        return err
+    }
+    f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+    if err != nil {
+        return err
+    }
+
+    src := r
+    if e.opts.MaxSize > 0 {
+        src = io.LimitReader(r, e.remaining+1)
+    }
+    n, err := io.Copy(f, src)
+    e.remaining -= n
+    if err == nil && e.opts.MaxSize > 0 && e.remaining < 0 {
+        err = ErrArchiveTooLarge
+    }
+    if err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return err
+    }
+    return os.Chmod(target, mode.Perm())
+}
+
+// symlink creates a symlink entry whose target must resolve inside the root, following
+// any symlinks extracted earlier.
+func (e *extractor) symlink(target, linkname string) error {
+    if filepath.IsAbs(linkname) || strings.HasPrefix(linkname, "/") {
+        return fmt.Errorf("archive symlink %s has absolute target %q", target, linkname)
+    }
+    if err := e.prepare(target); err != nil {
+        return err
+    }
+    parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+    if err != nil {
+        return err
+    }
+    if !e.resolveLink(parent, linkname) {
+        return fmt.Errorf("archive symlink %s points outside the destination", target)
+    }
+    if err := os.Symlink(linkname, target); err != nil {
+        return err
+    }
+    e.links = append(e.links, target)
+    return nil
+}
+
+// hardlink creates a hard link to an entry that was already extracted.
+func (e *extractor) hardlink(target, linkname string) error {
+    source, err := e.target(linkname)
+    if err != nil {
+        return err
+    }
+    if err := e.prepare(target); err != nil {
+        return err
+    }
+    real, err := filepath.EvalSymlinks(source)
+    if err != nil {
+        return err
+    }
+    if !within(e.realRoot, real) {
+        return fmt.Errorf("archive hard link %s points outside the destination", target)
+    }
+    return os.Link(real, target)
+}
+
+// extractTar unpacks a tar stream.
+func extractTar(r io.Reader, dstDir string, opts ArchiveOptions) error {
+    e, err := newExtractor(dstDir, opts)
+    if err != nil {
+        return err
+    }
+    return e.finish(e.tarEntries(tar.NewReader(r)))
+}
+
+// tarEntries extracts every entry of tr.
+func (e *extractor) tarEntries(tr *tar.Reader) error {
+    for {
+        hdr, err := tr.Next()
+        if errors.Is(err, io.EOF) {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+
+        isDir := hdr.Typeflag == tar.TypeDir
+        if archiveSkipped(e.opts, path.Clean(hdr.Name), isDir) {
+            continue
+        }
+        target, err := e.target(hdr.Name)
+        if err != nil {
+            return err
+        }
+        if target == "" {
+            continue
+        }
+        if e.opts.MaxSize > 0 && hdr.Size > e.remaining {
+            return ErrArchiveTooLarge
+        }
+
+        mode := fs.FileMode(hdr.Mode).Perm()
+        switch hdr.Typeflag {
+        case tar.TypeDir:
+            err = e.dir(target, mode)
+        case tar.TypeReg, tar.TypeRegA:
+            err = e.file(target, mode, tr)
+        case tar.TypeSymlink:
+            err = e.symlink(target, hdr.Linkname)
+        case tar.TypeLink:
+            err = e.hardlink(target, hdr.Linkname)
+        }
+        if err != nil {
+            return err
+        }
+    }
+}
+
+// extractZip unpacks a zip archive.
+func extractZip(r io.ReaderAt, size int64, dstDir string, opts ArchiveOptions) error {
+    zr, err := zip.NewReader(r, size)
+    if err != nil {
+        return err
+    }
+    e, err := newExtractor(dstDir, opts)
+    if err != nil {
+        return err
+    }
+    return e.finish(e.zipEntries(zr))
+}
+
+// zipEntries extracts every entry of zr.
+func (e *extractor) zipEntries(zr *zip.Reader) error {
+    for _, zf := range zr.File {
+        mode := zf.Mode()
+        if archiveSkipped(e.opts, path.Clean(zf.Name), mode.IsDir()) {
+            continue
+        }
+        target, err := e.target(zf.Name)
+        if err != nil {
+            return err
+        }
+        if target == "" {
+            continue
+        }
+        if e.opts.MaxSize > 0 && int64(zf.UncompressedSize64) > e.remaining {
+            return ErrArchiveTooLarge
+        }
+
+        if err := e.zipEntry(zf, target, mode); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// zipEntry extracts a single zip entry.
+func (e *extractor) zipEntry(zf *zip.File, target string, mode fs.FileMode) error {
+    if mode.IsDir() {
+        return e.dir(target, mode)
+    }
+
+    rc, err := zf.Open()
+    if err != nil {
+        return err
+    }
+    defer rc.Close()
+
+    if mode&fs.ModeSymlink != 0 {
+        link, err := io.ReadAll(io.LimitReader(rc, 4096))
+        if err != nil {
+            return err
+        }
+        return e.symlink(target, string(link))
+    }
+    if !mode.IsRegular() {
+        return nil
+    }
+    return e.file(target, mode, rc)
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+    "archive/tar"
+    "bytes"
+    "errors"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+)
+
+// tarEntry is one header of a hand-built test archive.
+type tarEntry struct {
+    name     string
+    linkname string
+    body     string
+}
+
+// buildTar returns a tar stream holding entries; those with a linkname are symlinks and
+// names ending in "/" are directories.
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+    t.Helper()
+    var buf bytes.Buffer
+    tw := tar.NewWriter(&buf)
+    for _, entry := range entries {
+        hdr := &tar.Header{Name: entry.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(entry.body))}
+        if entry.linkname != "" {
+            hdr = &tar.Header{Name: entry.name, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: entry.linkname}
+        } else if strings.HasSuffix(entry.name, "/") {
+            hdr = &tar.Header{Name: entry.name, Mode: 0755, Typeflag: tar.TypeDir}
+        }
+        if err := tw.WriteHeader(hdr); err != nil {
+            t.Fatal(err)
+        }
+        if _, err := tw.Write([]byte(entry.body)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := tw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return &buf
+}
+
+// escapingLinks returns the symlinks below dst that resolve outside it.
+func escapingLinks(t *testing.T, dst string) []string {
+    t.Helper()
+    realDst, err := filepath.EvalSymlinks(dst)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var escaped []string
+    filepath.WalkDir(dst, func(p string, d os.DirEntry, err error) error {
+        if err != nil || d.Type()&os.ModeSymlink == 0 {
+            return err
+        }
+        if real, err := filepath.EvalSymlinks(p); err == nil && !within(realDst, real) {
+            escaped = append(escaped, p)
+        }
+        return nil
+    })
+    return escaped
+}
+
+// TestReadArchiveSymlinkChainEscape checks that chained symlinks cannot place files, or
+// leave links, outside dstDir.
+func TestReadArchiveSymlinkChainEscape(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("symlinks need extra privileges on windows")
+    }
+
+    cases := map[string][]tarEntry{
+        "link through existing link": {
+            {name: "p", linkname: "."},
+            {name: "q", linkname: "p/.."},
+            {name: "q/ESCAPED/f", body: "x"},
+        },
+        "link through later link": {
+            {name: "r", linkname: "m/.."},
+            {name: "m", linkname: "."},
+        },
+        "link through replaced link": {
+            {name: "sub/"},
+            {name: "p", linkname: "sub"},
+            {name: "q", linkname: "p/.."},
+            {name: "p", linkname: "."},
+        },
+    }
+    for name, entries := range cases {
+        t.Run(name, func(t *testing.T) {
+            base := t.TempDir()
+            dst := filepath.Join(base, "dst")
+
+            err := ReadArchive(buildTar(t, entries), ArchiveTar, dst, ArchiveOptions{})
+            if err == nil {
+                t.Fatal("expected the archive to be rejected")
+            }
+            if _, err := os.Lstat(filepath.Join(base, "ESCAPED")); !os.IsNotExist(err) {
+                t.Fatalf("ESCAPED was created outside the destination: %v", err)
+            }
+            if escaped := escapingLinks(t, dst); len(escaped) > 0 {
+                t.Fatalf("symlinks left pointing outside the destination: %v", escaped)
+            }
+        })
+    }
+}
+
+// TestReadArchiveForwardSymlink checks that a link to an entry later in the archive is kept.
+func TestReadArchiveForwardSymlink(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("symlinks need extra privileges on windows")
+    }
+
+    dst := t.TempDir()
+    entries := []tarEntry{
+        {name: "lib/libx.so", linkname: "libx.so.1"},
+        {name: "lib/libx.so.1", body: "elf"},
+        {name: "current", linkname: "lib"},
+    }
+    if err := ReadArchive(buildTar(t, entries), ArchiveTar, dst, ArchiveOptions{}); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(filepath.Join(dst, "current", "libx.so"))
+    if err != nil || string(data) != "elf" {
+        t.Fatalf("got %q, %v", data, err)
+    }
+}
+
+// TestReadArchiveZipSpoolLimit checks that a zip stream larger than MaxSize is refused
+// while spooling, and that the spool file is kept next to dstDir and removed.
+func TestReadArchiveZipSpoolLimit(t *testing.T) {
+    base := t.TempDir()
+    src := filepath.Join(base, "src")
+    if err := os.MkdirAll(src, 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(src, "big"), bytes.Repeat([]byte("x"), 4096), 0644); err != nil {
+        t.Fatal(err)
+    }
+    var zipped bytes.Buffer
+    if err := WriteArchive(&zipped, src, ArchiveZip, ArchiveOptions{}); err != nil {
+        t.Fatal(err)
+    }
+
+    out := filepath.Join(base, "out")
+    err := ReadArchive(&zipped, ArchiveZip, filepath.Join(out, "dst"), ArchiveOptions{MaxSize: int64(zipped.Len() / 2)})
+    if !errors.Is(err, ErrArchiveTooLarge) {
+        t.Fatalf("got %v, want ErrArchiveTooLarge", err)
+    }
+    entries, err := os.ReadDir(out)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(entries) != 0 {
+        t.Fatalf("left %d entries next to dstDir", len(entries))
+    }
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+    "errors"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+)
+
+// DefaultBackupSuffix is appended to a file's path to name its backup copy.
+const DefaultBackupSuffix = ".bak"
+
+// WriteOptions controls how WriteFileWithOptions replaces a file.
+// Perm is only used when the file does not already exist and defaults to 0644.
+type WriteOptions struct {
+    Atomic       bool
+    Backup       bool
+    BackupSuffix string
+    Perm         os.FileMode
+}
+
+// WriteFileAtomic replaces a file so that readers and power loss only ever see the old or
+// the new content: it writes a temp file in the same directory, fsyncs it, renames it over
+// the target, then fsyncs the directory. The existing mode is kept, and so is the
+// ownership where the process is allowed to set it.
+func WriteFileAtomic(path string, content string) error {
+    return WriteFileWithOptions(path, content, WriteOptions{Atomic: true})
+}
+
+// WriteFileWithOptions writes content to path according to opts.
+// With Backup set, the previous version is kept at path+BackupSuffix.
+func WriteFileWithOptions(path string, content string, opts WriteOptions) error {
+    if opts.Perm == 0 {
+        opts.Perm = 0644
+    }
+    if opts.BackupSuffix == "" {
+        opts.BackupSuffix = DefaultBackupSuffix
+    }
+
+    // Write through symlinks rather than replacing the link itself.
+    if resolved, err := filepath.EvalSymlinks(path); err == nil {
+        path = resolved
+    }
+
+    info, err := os.Stat(path)
+    if err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    exists := err == nil
+
+    if opts.Backup && exists {
+        if err := backupFile(path, path+opts.BackupSuffix, opts.Atomic); err != nil {
+            return err
+        }
+    }
+
+    if !opts.Atomic {
+        perm := opts.Perm
+        if exists {
+            perm = info.Mode().Perm()
+        }
+        return os.WriteFile(path, []byte(content), perm)
+    }
+    return writeAtomic(path, []byte(content), info, opts.Perm)
+}
+
+// AppendToFileDurable appends content to a file like AppendToFile, then fsyncs it so the
+// data survives power loss. A newly created file also has its directory fsynced.
+func AppendToFileDurable(path string, content string) error {
+    _, statErr := os.Stat(path)
+    created := errors.Is(statErr, fs.ErrNotExist)
+
+    f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+    if err != nil {
+        return err
+    }
+
+    if _, err := f.WriteString(content); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Sync(); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return err
+    }
+
+    if created {
+        return syncDir(filepath.Dir(path))
+    }
+    return nil
+}
+
+// writeAtomic writes data to a synced temp file beside path and renames it into place.
+// info describes the existing file, or is nil when path does not exist yet.
+func writeAtomic(path string, data []byte, info os.FileInfo, perm os.FileMode) error {
+    dir := filepath.Dir(path)
+    tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+    if err != nil {
+        return err
+    }
+    tmpPath := tmp.Name()
+    defer os.Remove(tmpPath)
+
+    if info != nil {
+        perm = info.Mode().Perm()
+    }
+
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Chmod(perm); err != nil {
+        tmp.Close()
+        return err
+    }
+    if info != nil {
+        if err := copyOwnership(tmp, info); err != nil {
+            tmp.Close()
+            return err
+        }
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+
+    if err := os.Rename(tmpPath, path); err != nil {
+        return err
+    }
+    return syncDir(dir)
+}
+
+// backupFile preserves the current content of path at backupPath with a synced copy.
+// When link is set the caller replaces path by rename, leaving the old inode untouched,
+// so a hard link is tried first; an in-place write would change the linked backup too.
+func backupFile(path, backupPath string, link bool) error {
+    if err := os.Remove(backupPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    if link {
+        if err := os.Link(path, backupPath); err == nil {
+            return nil
+        }
+    }
+
+    src, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer src.Close()
+
+    info, err := src.Stat()
+    if err != nil {
+        return err
+    }
+
+    dst, err := os.OpenFile(backupPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(dst, src); err != nil {
+        dst.Close()
+        return err
+    }
+    if err := dst.Sync(); err != nil {
+        dst.Close()
+        return err
+    }
+    return dst.Close()
+}
@@ -0,0 +1,401 @@
+package utils
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "time"
+)
+
+// FileOp is the kind of operation planned by CopyDir and SyncDir.
+type FileOp string
+
+const (
+    OpMkdir   FileOp = "mkdir"
+    OpCopy    FileOp = "copy"
+    OpSymlink FileOp = "symlink"
+    OpDelete  FileOp = "delete"
+)
+
+// FileOperation is a single planned or performed step of a copy or sync.
+type FileOperation struct {
+    Op   FileOp
+    Src  string
+    Dst  string
+    Size int64
+}
+
+// CopyProgress is reported while operations are carried out. Bytes count file content
+// only; Ops count every operation including directories and symlinks.
+type CopyProgress struct {
+    Current    FileOperation
+    BytesDone  int64
+    BytesTotal int64
+    OpsDone    int
+    OpsTotal   int
+}
+
+// CopyOptions controls CopyDir. With DryRun nothing is changed and the planned
+// operations are only returned. Progress, if set, is called as data is copied.
+type CopyOptions struct {
+    DryRun   bool
+    Progress func(CopyProgress)
+}
+
+// SyncOptions controls SyncDir. Files are compared by size and modification time unless
+// Checksum is set, in which case equal-sized files are compared by SHA-256. With Delete,
+// entries in the destination that are missing from the source are removed.
+type SyncOptions struct {
+    CopyOptions
+    Checksum bool
+    Delete   bool
+}
+
+// CopyFile copies a single file or symlink, preserving permissions and modification time.
+// The destination is replaced atomically.
+func CopyFile(src, dst string) error {
+    info, err := os.Lstat(src)
+    if err != nil {
+        return err
+    }
+    if info.IsDir() {
+        return fmt.Errorf("%s is a directory", src)
+    }
+    op := planEntry(src, dst, info)
+    return runFileOps([]FileOperation{op}, CopyOptions{})
+}
+
+// CopyDir recursively copies src to dst, preserving permissions, timestamps and symlinks.
+// It returns the operations performed, or only planned when DryRun is set.
+func CopyDir(src, dst string, opts CopyOptions) ([]FileOperation, error) {
+    var ops []FileOperation
+    err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+        ops = append(ops, planEntry(path, mirrorPath(src, dst, path), info))
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    if opts.DryRun {
+        return ops, nil
+    }
+    return ops, runFileOps(ops, opts)
+}
+
+// SyncDir makes dst a copy of src, only copying entries that are new or have changed.
+// A destination entry of a different type, such as a symlink where the source now has a
+// directory, is removed first. It returns the operations performed, or only planned when
+// DryRun is set.
+func SyncDir(src, dst string, opts SyncOptions) ([]FileOperation, error) {
+    var ops []FileOperation
+    var replaced string
+    err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+
+        target := mirrorPath(src, dst, path)
+        if replaced != "" && within(replaced, target) {
+            // Everything below a replaced entry is new.
+            ops = append(ops, planEntry(path, target, info))
+            return nil
+        }
+        changed, err := entryChanged(path, target, info, opts.Checksum)
+        if err != nil {
+            return err
+        }
+        if !changed {
+            return nil
+        }
+        if dstInfo, err := os.Lstat(target); err == nil && dstInfo.Mode().Type() != info.Mode().Type() {
+            ops = append(ops, FileOperation{Op: OpDelete, Dst: target})
+            replaced = target
+        }
+        ops = append(ops, planEntry(path, target, info))
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    if opts.Delete {
+        extra, err := planDeletes(src, dst)
+        if err != nil {
+            return nil, err
+        }
+        ops = append(extra, ops...)
+    }
+
+    if opts.DryRun {
+        return ops, nil
+    }
+    return ops, runFileOps(ops, opts.CopyOptions)
+}
+
+// MoveFile renames src to dst, falling back to copy and delete when they are on
+// different filesystems. Directories are moved recursively.
+func MoveFile(src, dst string) error {
+    err := os.Rename(src, dst)
+    if err == nil || !isCrossDevice(err) {
+        return err
+    }
+
+    info, err := os.Lstat(src)
+    if err != nil {
+        return err
+    }
+    if info.IsDir() {
+        if _, err := CopyDir(src, dst, CopyOptions{}); err != nil {
+            return err
+        }
+        return os.RemoveAll(src)
+    }
+    if err := CopyFile(src, dst); err != nil {
+        return err
+    }
+    return os.Remove(src)
+}
+
+// mirrorPath maps a path under src to the same relative path under dst.
+func mirrorPath(src, dst, path string) string {
+    rel, err := filepath.Rel(src, path)
+    if err != nil || rel == "." {
+        return dst
+    }
+    return filepath.Join(dst, rel)
+}
+
+// planEntry returns the operation that recreates a source entry at dst.
+func planEntry(src, dst string, info fs.FileInfo) FileOperation {
+    switch {
+    case info.IsDir():
+        return FileOperation{Op: OpMkdir, Src: src, Dst: dst}
+    case info.Mode()&fs.ModeSymlink != 0:
+        return FileOperation{Op: OpSymlink, Src: src, Dst: dst}
+    default:
+        return FileOperation{Op: OpCopy, Src: src, Dst: dst, Size: info.Size()}
+    }
+}
+
+// entryChanged reports whether dst differs from the source entry described by info.
+func entryChanged(src, dst string, info fs.FileInfo, checksum bool) (bool, error) {
+    dstInfo, err := os.Lstat(dst)
+    if errors.Is(err, fs.ErrNotExist) {
+        return true, nil
+    }
+    if err != nil {
+        return false, err
+    }
+    if info.Mode().Type() != dstInfo.Mode().Type() {
+        return true, nil
+    }
+
+    switch {
+    case info.IsDir():
+        return info.Mode().Perm() != dstInfo.Mode().Perm(), nil
+    case info.Mode()&fs.ModeSymlink != 0:
+        a, err := os.Readlink(src)
+        if err != nil {
+            return false, err
+        }
+        b, err := os.Readlink(dst)
+        return a != b, err
+    }
+
+    if info.Size() != dstInfo.Size() || info.Mode().Perm() != dstInfo.Mode().Perm() {
+        return true, nil
+    }
+    if checksum {
+        return filesDiffer(src, dst)
+    }
+    // Compare at second granularity, as some filesystems store coarse timestamps.
+    return !info.ModTime().Truncate(time.Second).Equal(dstInfo.ModTime().Truncate(time.Second)), nil
+}
+
+// filesDiffer compares two files by SHA-256.
+func filesDiffer(a, b string) (bool, error) {
+    sumA, err := HashFile(a, HashSHA256)
+    if err != nil {
+        return false, err
+    }
+    sumB, err := HashFile(b, HashSHA256)
+    if err != nil {
+        return false, err
+    }
+    return sumA != sumB, nil
+}
+
+// planDeletes lists top-most entries under dst that have no counterpart under src.
+func planDeletes(src, dst string) ([]FileOperation, error) {
+    var ops []FileOperation
+    err := filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+        if errors.Is(err, fs.ErrNotExist) && path == dst {
+            return filepath.SkipAll
+        }
+        if err != nil {
+            return err
+        }
+        if path == dst {
+            return nil
+        }
+
+        counterpart := mirrorPath(dst, src, path)
+        srcInfo, err := os.Lstat(counterpart)
+        if err == nil {
+            // Entries that changed type are replaced by SyncDir as the source is planned.
+            if d.IsDir() && srcInfo.Mode().Type() != d.Type() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if !errors.Is(err, fs.ErrNotExist) {
+            return err
+        }
+
+        ops = append(ops, FileOperation{Op: OpDelete, Dst: path})
+        if d.IsDir() {
+            return filepath.SkipDir
+        }
+        return nil
+    })
+    return ops, err
+}
+
+// runFileOps carries out planned operations in order, then applies directory permissions
+// and timestamps deepest-first so that copying into a directory does not disturb them.
+func runFileOps(ops []FileOperation, opts CopyOptions) error {
+    progress := CopyProgress{OpsTotal: len(ops)}
+    for _, op := range ops {
+        progress.BytesTotal += op.Size
+    }
+    report := func() {
+        if opts.Progress != nil {
+            opts.Progress(progress)
+        }
+    }
+
+    var dirs []FileOperation
+    for _, op := range ops {
+        progress.Current = op
+        var err error
+        switch op.Op {
+        case OpMkdir:
+            err = os.MkdirAll(op.Dst, 0755)
+            dirs = append(dirs, op)
+        case OpSymlink:
+            err = copySymlink(op.Src, op.Dst)
+        case OpDelete:
+            err = os.RemoveAll(op.Dst)
+        case OpCopy:
+            err = copyFileContents(op.Src, op.Dst, func(n int64) {
+                progress.BytesDone += n
+                report()
+            })
+        }
+        if err != nil {
+            return err
+        }
+        progress.OpsDone++
+        report()
+    }
+
+    for i := len(dirs) - 1; i >= 0; i-- {
+        info, err := os.Stat(dirs[i].Src)
+        if err != nil {
+            return err
+        }
+        if err := os.Chmod(dirs[i].Dst, info.Mode().Perm()); err != nil {
+            return err
+        }
+        if err := os.Chtimes(dirs[i].Dst, info.ModTime(), info.ModTime()); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// copyFileContents copies a regular file via a temp file and rename, preserving its
+// mode and modification time. onWrite is called with each chunk size as it is written.
+func copyFileContents(src, dst string, onWrite func(int64)) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+
+    info, err := in.Stat()
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+        return err
+    }
+
+    tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := io.Copy(&progressWriter{w: tmp, onWrite: onWrite}, in); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), dst)
+}
+
+// copySymlink recreates the symlink src at dst, replacing whatever is there.
+func copySymlink(src, dst string) error {
+    target, err := os.Readlink(src)
+    if err != nil {
+        return err
+    }
+    if err := os.RemoveAll(dst); err != nil {
+        return err
+    }
+    return os.Symlink(target, dst)
+}
+
+// progressWriter reports the size of every write to onWrite.
+type progressWriter struct {
+    w       io.Writer
+    onWrite func(int64)
+}
+
+// Write implements io.Writer.
+func (p *progressWriter) Write(b []byte) (int, error) {
+    n, err := p.w.Write(b)
+    if n > 0 && p.onWrite != nil {
+        p.onWrite(int64(n))
+    }
+    return n, err
+}
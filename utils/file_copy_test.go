@@ -0,0 +1,95 @@
+package utils
+
+import (
+    "os"
+    "path/filepath"
+    "runtime"
+    "syscall"
+    "testing"
+)
+
+// TestSyncDirTypeChange checks that SyncDir replaces destination entries whose type
+// changed in the source, even without Delete.
+func TestSyncDirTypeChange(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("symlinks need extra privileges on windows")
+    }
+
+    base := t.TempDir()
+    src := filepath.Join(base, "src")
+    dst := filepath.Join(base, "dst")
+    outside := filepath.Join(base, "outside")
+    for _, dir := range []string{src, outside, filepath.Join(src, "was-dir")} {
+        if err := os.MkdirAll(dir, 0755); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := os.WriteFile(filepath.Join(src, "was-file"), []byte("f"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Symlink(outside, filepath.Join(src, "was-link")); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := SyncDir(src, dst, SyncOptions{}); err != nil {
+        t.Fatal(err)
+    }
+
+    // Swap every type: link -> dir, dir -> file, file -> link.
+    for _, name := range []string{"was-dir", "was-file", "was-link"} {
+        if err := os.RemoveAll(filepath.Join(src, name)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := os.MkdirAll(filepath.Join(src, "was-link"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(src, "was-link", "inner"), []byte("i"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(src, "was-dir"), []byte("d"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Symlink("was-dir", filepath.Join(src, "was-file")); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := SyncDir(src, dst, SyncOptions{}); err != nil {
+        t.Fatal(err)
+    }
+
+    if info, err := os.Lstat(filepath.Join(dst, "was-link")); err != nil || !info.IsDir() {
+        t.Fatalf("was-link: want a directory, got %v, %v", info, err)
+    }
+    if data, err := os.ReadFile(filepath.Join(dst, "was-link", "inner")); err != nil || string(data) != "i" {
+        t.Fatalf("was-link/inner: got %q, %v", data, err)
+    }
+    if _, err := os.Lstat(filepath.Join(outside, "inner")); !os.IsNotExist(err) {
+        t.Fatalf("inner was written through the old symlink: %v", err)
+    }
+    if info, err := os.Lstat(filepath.Join(dst, "was-dir")); err != nil || !info.Mode().IsRegular() {
+        t.Fatalf("was-dir: want a regular file, got %v, %v", info, err)
+    }
+    if link, err := os.Readlink(filepath.Join(dst, "was-file")); err != nil || link != "was-dir" {
+        t.Fatalf("was-file: want a symlink to was-dir, got %q, %v", link, err)
+    }
+
+    ops, err := SyncDir(src, dst, SyncOptions{})
+    if err != nil || len(ops) != 0 {
+        t.Fatalf("second sync: got %v, %v", ops, err)
+    }
+}
+
+// TestIsCrossDevice checks that the platform's cross-device rename error, as wrapped by
+// os.Rename, triggers MoveFile's copy fallback.
+func TestIsCrossDevice(t *testing.T) {
+    var errno syscall.Errno = syscall.EXDEV
+    if runtime.GOOS == "windows" {
+        errno = 17 // ERROR_NOT_SAME_DEVICE
+    }
+    if !isCrossDevice(&os.LinkError{Op: "rename", Old: "a", New: "b", Err: errno}) {
+        t.Fatal("cross-device rename error not recognised")
+    }
+    if isCrossDevice(&os.LinkError{Op: "rename", Old: "a", New: "b", Err: os.ErrPermission}) {
+        t.Fatal("permission error treated as cross-device")
+    }
+}
@@ -0,0 +1,339 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "sync"
+    "time"
+)
+
+// Default follower timings used when FollowOptions leaves them unset.
+const (
+    DefaultFollowPollInterval = 250 * time.Millisecond
+    DefaultCheckpointInterval = 1 * time.Second
+)
+
+// FollowOptions configures FollowFile.
+//
+// StateFile, if set, is where the follower checkpoints the offset of the last delivered
+// line; on start it resumes from there if the file is still the same one. Without a
+// checkpoint, following starts at the end of the file unless FromStart is set.
+// Lines longer than MaxLineLength are split.
+//
+// Delivery is at-least-once. The offset is saved every CheckpointInterval and when
+// following stops, so after a crash the lines delivered since the last checkpoint are
+// delivered again; a clean stop resumes without repeats. A failed checkpoint stops the
+// follower with that error.
+type FollowOptions struct {
+    StateFile          string
+    FromStart          bool
+    PollInterval       time.Duration
+    CheckpointInterval time.Duration
+    MaxLineLength      int
+}
+
+// Follower emits lines appended to a file, like tail -F. It survives truncation,
+// rename-and-recreate and copytruncate rotation.
+type Follower struct {
+    path  string
+    opts  FollowOptions
+    lines chan Line
+
+    mu  sync.Mutex
+    err error
+
+    file   *os.File
+    offset int64
+    lineNo int64
+    buf    []byte
+}
+
+// followState is the checkpoint persisted to FollowOptions.StateFile.
+type followState struct {
+    Path     string `json:"path"`
+    Identity uint64 `json:"identity"`
+    Offset   int64  `json:"offset"`
+    Line     int64  `json:"line"`
+}
+
+// FollowFile starts following path until ctx is done. The file does not need to exist yet.
+// The Lines channel is closed when following stops; Err then reports any fatal error.
+func FollowFile(ctx context.Context, path string, opts FollowOptions) (*Follower, error) {
+    if opts.PollInterval <= 0 {
+        opts.PollInterval = DefaultFollowPollInterval
+    }
+    if opts.CheckpointInterval <= 0 {
+        opts.CheckpointInterval = DefaultCheckpointInterval
+    }
+    if opts.MaxLineLength <= 0 {
+        opts.MaxLineLength = DefaultMaxLineLength
+    }
+
+    f := &Follower{path: path, opts: opts, lines: make(chan Line)}
+    if err := f.open(true); err != nil {
+        return nil, err
+    }
+
+    go f.run(ctx)
+    return f, nil
+}
+
+// Lines returns the channel on which appended lines are delivered.
+func (f *Follower) Lines() <-chan Line {
+    return f.lines
+}
+
+// Err returns the error that stopped the follower, or nil if it stopped because ctx was
+// done and the final checkpoint was saved.
+func (f *Follower) Err() error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.err
+}
+
+// open opens the followed file if it exists. On the first open the position comes from
+// the checkpoint or FollowOptions.FromStart; later opens (after rotation) start at 0.
+func (f *Follower) open(initial bool) error {
+    file, err := os.Open(f.path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return err
+    }
+
+    var offset int64
+    if initial {
+        state, ok := f.loadState()
+        switch {
+        case ok && state.Identity == fileIdentity(info) && state.Offset <= info.Size():
+            offset = state.Offset
+            f.lineNo = state.Line
+        case ok:
+            // The file was rotated while we were stopped; read the new one from the start.
+        case !f.opts.FromStart:
+            offset = info.Size()
+        }
+    }
+
+    if _, err := file.Seek(offset, io.SeekStart); err != nil {
+        file.Close()
+        return err
+    }
+    f.file = file
+    f.offset = offset
+    f.buf = nil
+    return nil
+}
+
+// run is the follow loop: read what is available, detect rotation, then wait.
+func (f *Follower) run(ctx context.Context) {
+    defer close(f.lines)
+    defer func() {
+        if f.file != nil {
+            f.file.Close()
+        }
+    }()
+
+    ticker := time.NewTicker(f.opts.PollInterval)
+    defer ticker.Stop()
+    lastCheckpoint := time.Now()
+    checkpointed := int64(-1)
+
+    for {
+        if f.file == nil {
+            if err := f.open(false); err != nil {
+                f.fail(err)
+                return
+            }
+        }
+
+        if f.file != nil {
+            err := f.drain(ctx)
+            if err == nil {
+                err = f.checkRotation(ctx)
+            }
+            if err != nil {
+                if ctx.Err() == nil {
+                    f.fail(err)
+                }
+                if err := f.checkpoint(); err != nil {
+                    f.fail(err)
+                }
+                return
+            }
+        }
+
+        if f.offset != checkpointed && time.Since(lastCheckpoint) >= f.opts.CheckpointInterval {
+            if err := f.checkpoint(); err != nil {
+                f.fail(err)
+                return
+            }
+            checkpointed = f.offset
+            lastCheckpoint = time.Now()
+        }
+
+        select {
+        case <-ctx.Done():
+            if err := f.checkpoint(); err != nil {
+                f.fail(err)
+            }
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// drain reads everything currently available and delivers complete lines.
+func (f *Follower) drain(ctx context.Context) error {
+    chunk := make([]byte, 32*1024)
+    for {
+        n, err := f.file.Read(chunk)
+        if n > 0 {
+            f.buf = append(f.buf, chunk[:n]...)
+            if err := f.emit(ctx, false); err != nil {
+                return err
+            }
+        }
+        if err == io.EOF || n == 0 {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+    }
+}
+
+// emit delivers every complete line in the buffer. With final set, a trailing partial
+// line is delivered too, because its file will not grow any further.
+func (f *Follower) emit(ctx context.Context, final bool) error {
+    for len(f.buf) > 0 {
+        i := bytes.IndexByte(f.buf, '\n')
+        var text []byte
+        var size int
+        switch {
+        case i >= 0 && i <= f.opts.MaxLineLength:
+            text, size = f.buf[:i], i+1
+        case len(f.buf) > f.opts.MaxLineLength:
+            text, size = f.buf[:f.opts.MaxLineLength], f.opts.MaxLineLength
+        case final:
+            text, size = f.buf, len(f.buf)
+        default:
+            return nil
+        }
+
+        line := Line{
+            Number: f.lineNo + 1,
+            Offset: f.offset,
+            End:    f.offset + int64(size),
+            Text:   string(bytes.TrimSuffix(text, []byte("\r"))),
+        }
+        select {
+        case f.lines <- line:
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+        f.lineNo++
+        f.offset = line.End
+        f.buf = f.buf[size:]
+    }
+    return nil
+}
+
+// checkRotation handles truncation (including copytruncate) and rename-and-recreate.
+func (f *Follower) checkRotation(ctx context.Context) error {
+    info, err := f.file.Stat()
+    if err != nil {
+        return err
+    }
+
+    readPos := f.offset + int64(len(f.buf))
+    if info.Size() < readPos {
+        if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+            return err
+        }
+        f.offset = 0
+        f.buf = nil
+        return nil
+    }
+
+    current, err := os.Stat(f.path)
+    if errors.Is(err, fs.ErrNotExist) {
+        // Renamed away and not yet recreated; keep reading the old file.
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if os.SameFile(info, current) {
+        return nil
+    }
+
+    // The path now points at a new file: flush the rest of the old one and switch.
+    if err := f.drain(ctx); err != nil {
+        return err
+    }
+    if err := f.emit(ctx, true); err != nil {
+        return err
+    }
+    f.file.Close()
+    f.file = nil
+    return f.open(false)
+}
+
+// loadState reads the checkpoint for this path, if there is one.
+func (f *Follower) loadState() (followState, bool) {
+    if f.opts.StateFile == "" {
+        return followState{}, false
+    }
+    data, err := os.ReadFile(f.opts.StateFile)
+    if err != nil {
+        return followState{}, false
+    }
+    var state followState
+    if err := FromJSON(data, &state); err != nil || state.Path != f.path {
+        return followState{}, false
+    }
+    return state, true
+}
+
+// checkpoint atomically persists the offset of the last delivered line.
+func (f *Follower) checkpoint() error {
+    if f.opts.StateFile == "" || f.file == nil {
+        return nil
+    }
+    info, err := f.file.Stat()
+    if err != nil {
+        return fmt.Errorf("checkpoint: %w", err)
+    }
+
+    state := followState{Path: f.path, Identity: fileIdentity(info), Offset: f.offset, Line: f.lineNo}
+    data, err := ToJSON(state)
+    if err != nil {
+        return fmt.Errorf("checkpoint: %w", err)
+    }
+    if err := WriteFileAtomic(f.opts.StateFile, string(data)); err != nil {
+        return fmt.Errorf("checkpoint: %w", err)
+    }
+    return nil
+}
+
+// fail records the first fatal error.
+func (f *Follower) fail(err error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if f.err == nil {
+        f.err = err
+    }
+}
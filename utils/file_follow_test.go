@@ -0,0 +1,163 @@
+package utils
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// startFollow follows path with fast polling and stops it when the test ends.
+func startFollow(t *testing.T, path string, opts FollowOptions) (*Follower, context.CancelFunc) {
+    t.Helper()
+    opts.PollInterval = 10 * time.Millisecond
+    ctx, cancel := context.WithCancel(context.Background())
+    f, err := FollowFile(ctx, path, opts)
+    if err != nil {
+        cancel()
+        t.Fatal(err)
+    }
+    t.Cleanup(func() {
+        cancel()
+        for range f.Lines() {
+        }
+    })
+    return f, cancel
+}
+
+// expectLines reads the next lines from f and compares their text with want.
+func expectLines(t *testing.T, f *Follower, want ...string) {
+    t.Helper()
+    for _, w := range want {
+        select {
+        case line, ok := <-f.Lines():
+            if !ok {
+                t.Fatalf("lines closed waiting for %q: %v", w, f.Err())
+            }
+            if line.Text != w {
+                t.Fatalf("got line %q, want %q", line.Text, w)
+            }
+        case <-time.After(5 * time.Second):
+            t.Fatalf("timed out waiting for %q", w)
+        }
+    }
+}
+
+// appendText appends s to path, creating it if needed.
+func appendText(t *testing.T, path, s string) {
+    t.Helper()
+    if err := AppendToFile(path, s); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// TestFollowFileTruncation checks that following restarts at the top of a truncated file.
+func TestFollowFileTruncation(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "app.log")
+    appendText(t, path, "one\ntwo\n")
+
+    f, _ := startFollow(t, path, FollowOptions{FromStart: true})
+    expectLines(t, f, "one", "two")
+
+    if err := os.WriteFile(path, []byte("3\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    expectLines(t, f, "3")
+}
+
+// TestFollowFileCopyTruncate checks copytruncate rotation: the file is copied away,
+// truncated in place and then written again.
+func TestFollowFileCopyTruncate(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "app.log")
+    appendText(t, path, "one\n")
+
+    f, _ := startFollow(t, path, FollowOptions{FromStart: true})
+    expectLines(t, f, "one")
+
+    appendText(t, path, "two\n")
+    expectLines(t, f, "two")
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(path+".1", data, 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Truncate(path, 0); err != nil {
+        t.Fatal(err)
+    }
+    time.Sleep(50 * time.Millisecond)
+    appendText(t, path, "3\n")
+    expectLines(t, f, "3")
+}
+
+// TestFollowFileRenameRecreate checks that lines written to the old file before the
+// rename are delivered, followed by the lines of the new file.
+func TestFollowFileRenameRecreate(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "app.log")
+    appendText(t, path, "one\n")
+
+    f, _ := startFollow(t, path, FollowOptions{FromStart: true})
+    expectLines(t, f, "one")
+
+    appendText(t, path, "two\npartial")
+    if err := os.Rename(path, path+".1"); err != nil {
+        t.Fatal(err)
+    }
+    appendText(t, path, "new\n")
+    expectLines(t, f, "two", "partial", "new")
+}
+
+// TestFollowFileResume checks that a follower stopped cleanly resumes after the last
+// delivered line.
+func TestFollowFileResume(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "app.log")
+    state := filepath.Join(dir, "app.state")
+    appendText(t, path, "one\ntwo\n")
+
+    f, cancel := startFollow(t, path, FollowOptions{FromStart: true, StateFile: state})
+    expectLines(t, f, "one", "two")
+    cancel()
+    for range f.Lines() {
+    }
+    if err := f.Err(); err != nil {
+        t.Fatal(err)
+    }
+
+    appendText(t, path, "three\n")
+    f, _ = startFollow(t, path, FollowOptions{FromStart: true, StateFile: state})
+    select {
+    case line := <-f.Lines():
+        if line.Text != "three" || line.Number != 3 {
+            t.Fatalf("resumed at line %d %q, want 3 \"three\"", line.Number, line.Text)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("timed out waiting for the resumed line")
+    }
+}
+
+// TestFollowFileCheckpointError checks that a checkpoint that cannot be saved stops the
+// follower with an error.
+func TestFollowFileCheckpointError(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "app.log")
+    appendText(t, path, "one\n")
+
+    opts := FollowOptions{FromStart: true, StateFile: filepath.Join(dir, "missing", "app.state"), CheckpointInterval: time.Millisecond}
+    f, _ := startFollow(t, path, opts)
+    expectLines(t, f, "one")
+
+    select {
+    case _, ok := <-f.Lines():
+        if ok {
+            t.Fatal("unexpected line")
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("follower kept running after a failed checkpoint")
+    }
+    if f.Err() == nil {
+        t.Fatal("Err is nil after a failed checkpoint")
+    }
+}
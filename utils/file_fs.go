@@ -0,0 +1,710 @@
+package utils
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// ErrReadOnlyFS is returned by write operations on a filesystem from NewReadOnlyFS.
+var ErrReadOnlyFS = fmt.Errorf("read-only filesystem: %w", fs.ErrPermission)
+
+// ErrPathEscapesRoot is returned by a base-path filesystem for names that resolve outside its root.
+var ErrPathEscapesRoot = fmt.Errorf("path escapes filesystem root: %w", fs.ErrPermission)
+
+// WritableFile is a file opened through a WritableFS.
+type WritableFile interface {
+    fs.File
+    io.Writer
+}
+
+// WritableFS is an fs.FS that also supports writes. Names follow io/fs conventions:
+// slash-separated and relative to the filesystem root.
+type WritableFS interface {
+    fs.StatFS
+    fs.ReadDirFS
+    OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error)
+    MkdirAll(name string, perm fs.FileMode) error
+    Remove(name string) error
+    Rename(oldname, newname string) error
+}
+
+// osFS is a WritableFS backed by a directory on disk.
+type osFS struct {
+    dir string
+}
+
+// NewOSFS returns a WritableFS for the directory tree rooted at dir, like os.DirFS with writes.
+func NewOSFS(dir string) WritableFS {
+    return &osFS{dir: dir}
+}
+
+// path validates name and converts it to an OS path below the root directory.
+func (o *osFS) path(op, name string) (string, error) {
+    if !fs.ValidPath(name) {
+        return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+    }
+    return filepath.Join(o.dir, filepath.FromSlash(name)), nil
+}
+
+// Open implements fs.FS.
+func (o *osFS) Open(name string) (fs.File, error) {
+    p, err := o.path("open", name)
+    if err != nil {
+        return nil, err
+    }
+    return os.Open(p)
+}
+
+// Stat implements fs.StatFS.
+func (o *osFS) Stat(name string) (fs.FileInfo, error) {
+    p, err := o.path("stat", name)
+    if err != nil {
+        return nil, err
+    }
+    return os.Stat(p)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (o *osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+    p, err := o.path("readdir", name)
+    if err != nil {
+        return nil, err
+    }
+    return os.ReadDir(p)
+}
+
+// OpenFile opens a file with os.OpenFile flags.
+func (o *osFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+    p, err := o.path("open", name)
+    if err != nil {
+        return nil, err
+    }
+    return os.OpenFile(p, flag, perm)
+}
+
+// MkdirAll creates a directory and any missing parents.
+func (o *osFS) MkdirAll(name string, perm fs.FileMode) error {
+    p, err := o.path("mkdir", name)
+    if err != nil {
+        return err
+    }
+    return os.MkdirAll(p, perm)
+}
+
+// Remove removes a file or empty directory.
+func (o *osFS) Remove(name string) error {
+    p, err := o.path("remove", name)
+    if err != nil {
+        return err
+    }
+    return os.Remove(p)
+}
+
+// Rename moves a file or directory.
+func (o *osFS) Rename(oldname, newname string) error {
+    from, err := o.path("rename", oldname)
+    if err != nil {
+        return err
+    }
+    to, err := o.path("rename", newname)
+    if err != nil {
+        return err
+    }
+    return os.Rename(from, to)
+}
+
+// readOnlyFS adapts any fs.FS, such as embed.FS, to WritableFS with every write refused.
+type readOnlyFS struct {
+    fsys fs.FS
+}
+
+// NewReadOnlyFS wraps fsys, typically an embed.FS, so it can be used wherever a WritableFS
+// is expected. Reads pass through; writes fail with ErrReadOnlyFS.
+func NewReadOnlyFS(fsys fs.FS) WritableFS {
+    return &readOnlyFS{fsys: fsys}
+}
+
+// Open implements fs.FS.
+func (r *readOnlyFS) Open(name string) (fs.File, error) {
+    return r.fsys.Open(name)
+}
+
+// Stat implements fs.StatFS.
+func (r *readOnlyFS) Stat(name string) (fs.FileInfo, error) {
+    return fs.Stat(r.fsys, name)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (r *readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+    return fs.ReadDir(r.fsys, name)
+}
+
+// OpenFile opens name for reading; any write, create or truncate flag is refused.
+func (r *readOnlyFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+    if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: ErrReadOnlyFS}
+    }
+    f, err := r.fsys.Open(name)
+    if err != nil {
+        return nil, err
+    }
+    return readOnlyFile{File: f, name: name}, nil
+}
+
+// MkdirAll always fails with ErrReadOnlyFS.
+func (r *readOnlyFS) MkdirAll(name string, perm fs.FileMode) error {
+    return &fs.PathError{Op: "mkdir", Path: name, Err: ErrReadOnlyFS}
+}
+
+// Remove always fails with ErrReadOnlyFS.
+func (r *readOnlyFS) Remove(name string) error {
+    return &fs.PathError{Op: "remove", Path: name, Err: ErrReadOnlyFS}
+}
+
+// Rename always fails with ErrReadOnlyFS.
+func (r *readOnlyFS) Rename(oldname, newname string) error {
+    return &fs.PathError{Op: "rename", Path: oldname, Err: ErrReadOnlyFS}
+}
+
+// readOnlyFile is an fs.File whose Write always fails.
+type readOnlyFile struct {
+    fs.File
+    name string
+}
+
+// Write always fails with ErrReadOnlyFS.
+func (f readOnlyFile) Write(p []byte) (int, error) {
+    return 0, &fs.PathError{Op: "write", Path: f.name, Err: ErrReadOnlyFS}
+}
+
+// basePathFS confines another WritableFS to a subdirectory.
+type basePathFS struct {
+    base WritableFS
+    root string
+}
+
+// NewBasePathFS returns a chroot-style view of base rooted at dir, which is a name inside
+// base such as "srv/app"; a leading "/" is ignored, so NewBasePathFS(NewOSFS("/"), "/srv/app")
+// works too. If dir is not a valid name every operation fails with fs.ErrInvalid. Names may
+// be absolute ("/etc/app.conf" means dir/etc/app.conf) and are cleaned, but any name that
+// would resolve above the root is refused with ErrPathEscapesRoot. When base is on disk
+// (NewOSFS, possibly under other base-path views) symlinks are resolved before every
+// operation and a name that leads outside the root through one is refused too; Remove and
+// Rename act on a link itself, so only its parent directories are checked. The check
+// cannot stop another process from swapping in a symlink between it and the operation.
+func NewBasePathFS(base WritableFS, dir string) WritableFS {
+    root := strings.TrimLeft(filepath.ToSlash(dir), "/")
+    if root == "" {
+        root = "."
+    }
+    return &basePathFS{base: base, root: path.Clean(root)}
+}
+
+// resolve maps name into the base filesystem, refusing escapes. With followLast set, a
+// symlink as the final element is resolved too, as for operations that open its target.
+func (b *basePathFS) resolve(op, name string, followLast bool) (string, error) {
+    if !fs.ValidPath(b.root) {
+        return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+    }
+    clean := path.Clean(strings.TrimLeft(filepath.ToSlash(name), "/"))
+    if clean == ".." || strings.HasPrefix(clean, "../") {
+        return "", &fs.PathError{Op: op, Path: name, Err: ErrPathEscapesRoot}
+    }
+    if err := b.confine(clean, followLast); err != nil {
+        return "", &fs.PathError{Op: op, Path: name, Err: err}
+    }
+    return path.Join(b.root, clean), nil
+}
+
+// confine checks that clean, a cleaned name below the root, does not leave the root
+// through a symlink. It only applies when the base filesystem is on disk.
+func (b *basePathFS) confine(clean string, followLast bool) error {
+    dir, ok := diskDir(b.base)
+    if !ok {
+        return nil
+    }
+    rootDir, err := filepath.Abs(filepath.Join(dir, filepath.FromSlash(b.root)))
+    if err != nil {
+        return err
+    }
+    root, err := evalExisting(rootDir)
+    if err != nil {
+        return err
+    }
+
+    target := filepath.Join(rootDir, filepath.FromSlash(clean))
+    var real string
+    if followLast || clean == "." {
+        real, err = evalExisting(target)
+    } else {
+        real, err = evalExisting(filepath.Dir(target))
+        real = filepath.Join(real, filepath.Base(target))
+    }
+    if err != nil {
+        return err
+    }
+
+    rel, err := filepath.Rel(root, real)
+    if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return ErrPathEscapesRoot
+    }
+    return nil
+}
+
+// diskDir returns the directory on disk that fsys reads and writes, if it has one.
+func diskDir(fsys WritableFS) (string, bool) {
+    switch f := fsys.(type) {
+    case *osFS:
+        return f.dir, true
+    case *basePathFS:
+        dir, ok := diskDir(f.base)
+        return filepath.Join(dir, filepath.FromSlash(f.root)), ok
+    }
+    return "", false
+}
+
+// evalExisting resolves every symlink in p. Missing trailing elements are kept as they
+// are, except that a dangling symlink is followed to where creating through it would land.
+func evalExisting(p string) (string, error) {
+    var rest []string
+    for hops := 0; ; {
+        real, err := filepath.EvalSymlinks(p)
+        if err == nil {
+            return filepath.Join(append([]string{real}, rest...)...), nil
+        }
+        if !errors.Is(err, fs.ErrNotExist) {
+            return "", err
+        }
+
+        if info, lerr := os.Lstat(p); lerr == nil && info.Mode()&fs.ModeSymlink != 0 {
+            if hops++; hops > 255 {
+                return "", fmt.Errorf("too many links resolving %s", p)
+            }
+            target, err := os.Readlink(p)
+            if err != nil {
+                return "", err
+            }
+            if !filepath.IsAbs(target) {
+                target = filepath.Join(filepath.Dir(p), target)
+            }
+            p = target
+            continue
+        }
+
+        parent := filepath.Dir(p)
+        if parent == p {
+            return "", err
+        }
+        rest = append([]string{filepath.Base(p)}, rest...)
+        p = parent
+    }
+}
+
+// Open implements fs.FS.
+func (b *basePathFS) Open(name string) (fs.File, error) {
+    p, err := b.resolve("open", name, true)
+    if err != nil {
+        return nil, err
+    }
+    return b.base.Open(p)
+}
+
+// Stat implements fs.StatFS.
+func (b *basePathFS) Stat(name string) (fs.FileInfo, error) {
+    p, err := b.resolve("stat", name, true)
+    if err != nil {
+        return nil, err
+    }
+    return b.base.Stat(p)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (b *basePathFS) ReadDir(name string) ([]fs.DirEntry, error) {
+    p, err := b.resolve("readdir", name, true)
+    if err != nil {
+        return nil, err
+    }
+    return b.base.ReadDir(p)
+}
+
+// OpenFile opens a file with os.OpenFile flags.
+func (b *basePathFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+    p, err := b.resolve("open", name, true)
+    if err != nil {
+        return nil, err
+    }
+    return b.base.OpenFile(p, flag, perm)
+}
+
+// MkdirAll creates a directory and any missing parents.
+func (b *basePathFS) MkdirAll(name string, perm fs.FileMode) error {
+    p, err := b.resolve("mkdir", name, true)
+    if err != nil {
+        return err
+    }
+    return b.base.MkdirAll(p, perm)
+}
+
+// Remove removes a file or empty directory.
+func (b *basePathFS) Remove(name string) error {
+    p, err := b.resolve("remove", name, false)
+    if err != nil {
+        return err
+    }
+    return b.base.Remove(p)
+}
+
+// Rename moves a file or directory.
+func (b *basePathFS) Rename(oldname, newname string) error {
+    from, err := b.resolve("rename", oldname, false)
+    if err != nil {
+        return err
+    }
+    to, err := b.resolve("rename", newname, false)
+    if err != nil {
+        return err
+    }
+    return b.base.Rename(from, to)
+}
+
+// MemFS is an in-memory WritableFS, intended for tests. It is safe for concurrent use.
+type MemFS struct {
+    mu    sync.RWMutex
+    nodes map[string]*memNode
+}
+
+// memNode is a file or directory held by a MemFS.
+type memNode struct {
+    data    []byte
+    mode    fs.FileMode
+    modTime time.Time
+}
+
+// NewMemFS returns an empty in-memory filesystem.
+func NewMemFS() *MemFS {
+    return &MemFS{nodes: map[string]*memNode{
+        ".": {mode: fs.ModeDir | 0755, modTime: time.Now()},
+    }}
+}
+
+// Open implements fs.FS.
+func (m *MemFS) Open(name string) (fs.File, error) {
+    return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements fs.StatFS.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+    if !fs.ValidPath(name) {
+        return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    node, ok := m.nodes[name]
+    if !ok {
+        return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+    }
+    return node.info(name), nil
+}
+
+// ReadDir implements fs.ReadDirFS. Entries are sorted by name.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+    if !fs.ValidPath(name) {
+        return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    node, ok := m.nodes[name]
+    if !ok {
+        return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+    }
+    if !node.mode.IsDir() {
+        return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+    }
+    return m.children(name), nil
+}
+
+// OpenFile opens a file with os.OpenFile flags. Creating a file requires its parent
+// directory to exist.
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+    if !fs.ValidPath(name) {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+    node, ok := m.nodes[name]
+    switch {
+    case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+    case !ok && flag&os.O_CREATE == 0:
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+    case !ok:
+        parent, exists := m.nodes[path.Dir(name)]
+        if !exists || !parent.mode.IsDir() {
+            return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+        }
+        node = &memNode{mode: perm.Perm(), modTime: time.Now()}
+        m.nodes[name] = node
+    case node.mode.IsDir() && writable:
+        return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+    }
+
+    if node.mode.IsDir() {
+        return &memDir{info: node.info(name), entries: m.children(name)}, nil
+    }
+    if writable && flag&os.O_TRUNC != 0 {
+        node.data = nil
+        node.modTime = time.Now()
+    }
+    return &memFile{fs: m, name: name, node: node, flag: flag}, nil
+}
+
+// MkdirAll creates a directory and any missing parents.
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+    if !fs.ValidPath(name) {
+        return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if name == "." {
+        return nil
+    }
+    dir := ""
+    for _, part := range strings.Split(name, "/") {
+        dir = path.Join(dir, part)
+        node, ok := m.nodes[dir]
+        if !ok {
+            m.nodes[dir] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+            continue
+        }
+        if !node.mode.IsDir() {
+            return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+        }
+    }
+    return nil
+}
+
+// Remove removes a file or empty directory.
+func (m *MemFS) Remove(name string) error {
+    if !fs.ValidPath(name) || name == "." {
+        return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    node, ok := m.nodes[name]
+    if !ok {
+        return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+    }
+    if node.mode.IsDir() && len(m.children(name)) > 0 {
+        return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+    }
+    delete(m.nodes, name)
+    return nil
+}
+
+// Rename moves a file or directory, with everything below it. An existing file at
+// newname is replaced; an existing directory is not.
+func (m *MemFS) Rename(oldname, newname string) error {
+    if !fs.ValidPath(oldname) || !fs.ValidPath(newname) || oldname == "." || newname == "." {
+        return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    node, ok := m.nodes[oldname]
+    if !ok {
+        return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+    }
+    if parent, ok := m.nodes[path.Dir(newname)]; !ok || !parent.mode.IsDir() {
+        return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrNotExist}
+    }
+    if existing, ok := m.nodes[newname]; ok && existing.mode.IsDir() {
+        return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+    }
+    if node.mode.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+        return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+    }
+
+    prefix := oldname + "/"
+    for p, n := range m.nodes {
+        if strings.HasPrefix(p, prefix) {
+            delete(m.nodes, p)
+            m.nodes[newname+"/"+strings.TrimPrefix(p, prefix)] = n
+        }
+    }
+    delete(m.nodes, oldname)
+    m.nodes[newname] = node
+    return nil
+}
+
+// children lists the direct entries of dir sorted by name. The caller holds the lock.
+func (m *MemFS) children(dir string) []fs.DirEntry {
+    var entries []fs.DirEntry
+    for p, node := range m.nodes {
+        if p != "." && path.Dir(p) == dir {
+            entries = append(entries, fs.FileInfoToDirEntry(node.info(p)))
+        }
+    }
+    sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+    return entries
+}
+
+// info returns a snapshot of the node's metadata.
+func (n *memNode) info(name string) fs.FileInfo {
+    return memFileInfo{name: path.Base(name), size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+// memFile is an open regular file in a MemFS.
+type memFile struct {
+    fs     *MemFS
+    name   string
+    node   *memNode
+    flag   int
+    offset int64
+    closed bool
+}
+
+// Stat implements fs.File.
+func (f *memFile) Stat() (fs.FileInfo, error) {
+    f.fs.mu.RLock()
+    defer f.fs.mu.RUnlock()
+    return f.node.info(f.name), nil
+}
+
+// Read implements fs.File.
+func (f *memFile) Read(p []byte) (int, error) {
+    if f.closed {
+        return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+    }
+    if f.flag&os.O_WRONLY != 0 {
+        return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+    }
+    f.fs.mu.RLock()
+    defer f.fs.mu.RUnlock()
+
+    if f.offset >= int64(len(f.node.data)) {
+        return 0, io.EOF
+    }
+    n := copy(p, f.node.data[f.offset:])
+    f.offset += int64(n)
+    return n, nil
+}
+
+// Write implements io.Writer.
+func (f *memFile) Write(p []byte) (int, error) {
+    if f.closed {
+        return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrClosed}
+    }
+    if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+        return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+    }
+    f.fs.mu.Lock()
+    defer f.fs.mu.Unlock()
+
+    if f.flag&os.O_APPEND != 0 {
+        f.offset = int64(len(f.node.data))
+    }
+    end := f.offset + int64(len(p))
+    if end > int64(len(f.node.data)) {
+        grown := make([]byte, end)
+        copy(grown, f.node.data)
+        f.node.data = grown
+    }
+    copy(f.node.data[f.offset:], p)
+    f.offset = end
+    f.node.modTime = time.Now()
+    return len(p), nil
+}
+
+// Close implements fs.File.
+func (f *memFile) Close() error {
+    if f.closed {
+        return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+    }
+    f.closed = true
+    return nil
+}
+
+// memDir is an open directory in a MemFS, listing the entries present when it was opened.
+type memDir struct {
+    info    fs.FileInfo
+    entries []fs.DirEntry
+}
+
+// Stat implements fs.File.
+func (d *memDir) Stat() (fs.FileInfo, error) {
+    return d.info, nil
+}
+
+// Read implements fs.File; directories cannot be read.
+func (d *memDir) Read(p []byte) (int, error) {
+    return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+// Write implements io.Writer; directories cannot be written.
+func (d *memDir) Write(p []byte) (int, error) {
+    return 0, &fs.PathError{Op: "write", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+// Close implements fs.File.
+func (d *memDir) Close() error {
+    return nil
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+    if n <= 0 {
+        entries := d.entries
+        d.entries = nil
+        return entries, nil
+    }
+    if len(d.entries) == 0 {
+        return nil, io.EOF
+    }
+    if n > len(d.entries) {
+        n = len(d.entries)
+    }
+    entries := d.entries[:n]
+    d.entries = d.entries[n:]
+    return entries, nil
+}
+
+// memFileInfo implements fs.FileInfo for MemFS nodes.
+type memFileInfo struct {
+    name    string
+    size    int64
+    mode    fs.FileMode
+    modTime time.Time
+}
+
+// Name implements fs.FileInfo.
+func (i memFileInfo) Name() string { return i.name }
+
+// Size implements fs.FileInfo.
+func (i memFileInfo) Size() int64 { return i.size }
+
+// Mode implements fs.FileInfo.
+func (i memFileInfo) Mode() fs.FileMode { return i.mode }
+
+// ModTime implements fs.FileInfo.
+func (i memFileInfo) ModTime() time.Time { return i.modTime }
+
+// IsDir implements fs.FileInfo.
+func (i memFileInfo) IsDir() bool { return i.mode.IsDir() }
+
+// Sys implements fs.FileInfo.
+func (i memFileInfo) Sys() interface{} { return nil }
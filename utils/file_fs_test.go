@@ -0,0 +1,113 @@
+package utils
+
+import (
+    "errors"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "runtime"
+    "testing"
+)
+
+// TestBasePathFSOverOSFS checks a BasePathFS whose base is an on-disk OSFS.
+func TestBasePathFSOverOSFS(t *testing.T) {
+    dir := t.TempDir()
+    if err := os.MkdirAll(filepath.Join(dir, "jail", "etc"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("s"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    roots := map[string]WritableFS{
+        "relative dir": NewBasePathFS(NewOSFS(dir), "jail"),
+        "leading slash": NewBasePathFS(NewOSFS(dir), "/jail"),
+    }
+    if runtime.GOOS != "windows" {
+        roots["filesystem root"] = NewBasePathFS(NewOSFS("/"), filepath.Join(dir, "jail"))
+    }
+
+    for name, fsys := range roots {
+        t.Run(name, func(t *testing.T) {
+            if err := WriteFileFS(fsys, "/etc/app.conf", name); err != nil {
+                t.Fatal(err)
+            }
+            data, err := os.ReadFile(filepath.Join(dir, "jail", "etc", "app.conf"))
+            if err != nil || string(data) != name {
+                t.Fatalf("got %q, %v", data, err)
+            }
+            if _, err := fsys.Stat("etc/app.conf"); err != nil {
+                t.Fatal(err)
+            }
+            if _, err := fsys.Open("../secret"); !errors.Is(err, ErrPathEscapesRoot) {
+                t.Fatalf("escape: got %v", err)
+            }
+        })
+    }
+
+    if _, err := NewBasePathFS(NewOSFS(dir), "../x").Stat("f"); !errors.Is(err, fs.ErrInvalid) {
+        t.Fatalf("invalid dir: got %v", err)
+    }
+}
+
+// TestBasePathFSSymlinkEscape checks that symlinks inside an on-disk base-path view
+// cannot be used to read or write outside its root.
+func TestBasePathFSSymlinkEscape(t *testing.T) {
+    skipWithoutSymlinks(t)
+    dir := t.TempDir()
+    jail := filepath.Join(dir, "jail")
+    outside := filepath.Join(dir, "outside")
+    for _, d := range []string{filepath.Join(jail, "inner"), outside} {
+        if err := os.MkdirAll(d, 0755); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("s"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    links := map[string]string{
+        "out":      outside,
+        "dangling": filepath.Join(outside, "created"),
+        "ok":       "inner",
+    }
+    for name, target := range links {
+        if err := os.Symlink(target, filepath.Join(jail, name)); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    fsys := NewBasePathFS(NewOSFS(dir), "jail")
+    if _, err := fsys.Open("out/secret"); !errors.Is(err, ErrPathEscapesRoot) {
+        t.Fatalf("read through link: got %v", err)
+    }
+    if _, err := fsys.ReadDir("out"); !errors.Is(err, ErrPathEscapesRoot) {
+        t.Fatalf("readdir through link: got %v", err)
+    }
+    if err := WriteFileFS(fsys, "out/new", "x"); !errors.Is(err, ErrPathEscapesRoot) {
+        t.Fatalf("write through link: got %v", err)
+    }
+    if err := WriteFileFS(fsys, "dangling", "x"); !errors.Is(err, ErrPathEscapesRoot) {
+        t.Fatalf("create through dangling link: got %v", err)
+    }
+    if _, err := os.Stat(filepath.Join(outside, "created")); !os.IsNotExist(err) {
+        t.Fatalf("file created outside the root: %v", err)
+    }
+
+    nested := NewBasePathFS(fsys, "inner")
+    if err := os.Symlink("../out", filepath.Join(jail, "inner", "up")); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := nested.Stat("up/secret"); !errors.Is(err, ErrPathEscapesRoot) {
+        t.Fatalf("nested view: got %v", err)
+    }
+
+    if err := WriteFileFS(fsys, "ok/file", "in"); err != nil {
+        t.Fatalf("link inside the root: %v", err)
+    }
+    if err := fsys.Remove("out"); err != nil {
+        t.Fatalf("removing the link itself: %v", err)
+    }
+    if _, err := os.Stat(filepath.Join(outside, "secret")); err != nil {
+        t.Fatalf("link target was touched: %v", err)
+    }
+}
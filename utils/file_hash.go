@@ -0,0 +1,196 @@
+package utils
+
+import (
+    "crypto/md5"
+    "crypto/sha1"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "hash"
+    "hash/crc32"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// HashAlgorithm names a supported file hash.
+type HashAlgorithm string
+
+const (
+    HashSHA256 HashAlgorithm = "sha256"
+    HashSHA1   HashAlgorithm = "sha1"
+    HashMD5    HashAlgorithm = "md5"
+    HashCRC32  HashAlgorithm = "crc32"
+)
+
+// ManifestEntry describes one regular file in a Manifest. Path is slash-separated and
+// relative to the manifest root; Mode is the octal permission bits, e.g. "0644".
+type ManifestEntry struct {
+    Path string `json:"path"`
+    Size int64  `json:"size"`
+    Mode string `json:"mode"`
+    Hash string `json:"hash"`
+}
+
+// Manifest lists the files of a directory tree with their sizes, modes and hashes.
+type Manifest struct {
+    Algorithm HashAlgorithm   `json:"algorithm"`
+    Files     []ManifestEntry `json:"files"`
+}
+
+// ManifestDiff is the result of verifying a tree against a Manifest.
+type ManifestDiff struct {
+    Missing []string
+    Extra   []string
+    Changed []string
+}
+
+// NewHash returns a new hash.Hash for the algorithm.
+func NewHash(algo HashAlgorithm) (hash.Hash, error) {
+    switch algo {
+    case HashSHA256:
+        return sha256.New(), nil
+    case HashSHA1:
+        return sha1.New(), nil
+    case HashMD5:
+        return md5.New(), nil
+    case HashCRC32:
+        return crc32.NewIEEE(), nil
+    }
+    return nil, fmt.Errorf("unsupported hash algorithm %q", algo)
+}
+
+// HashReader streams r through the algorithm and returns the hex-encoded digest.
+func HashReader(r io.Reader, algo HashAlgorithm) (string, error) {
+    h, err := NewHash(algo)
+    if err != nil {
+        return "", err
+    }
+    if _, err := io.Copy(h, r); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashFile returns the hex-encoded digest of a file without loading it into memory.
+func HashFile(path string, algo HashAlgorithm) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+    return HashReader(f, algo)
+}
+
+// GenerateManifest hashes every regular file under root. Symlinks are not followed.
+func GenerateManifest(root string, algo HashAlgorithm) (*Manifest, error) {
+    if _, err := NewHash(algo); err != nil {
+        return nil, err
+    }
+
+    m := &Manifest{Algorithm: algo, Files: []ManifestEntry{}}
+    err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if !d.Type().IsRegular() {
+            return nil
+        }
+        entry, err := manifestEntry(root, path, algo)
+        if err != nil {
+            return err
+        }
+        m.Files = append(m.Files, entry)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+
+// WriteManifest writes a manifest as indented JSON, replacing the file atomically.
+func WriteManifest(path string, m *Manifest) error {
+    data, err := ToJSONString(m)
+    if err != nil {
+        return err
+    }
+    return WriteFileAtomic(path, data+"\n")
+}
+
+// LoadManifest reads a manifest written by WriteManifest.
+func LoadManifest(path string) (*Manifest, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var m Manifest
+    if err := FromJSON(data, &m); err != nil {
+        return nil, fmt.Errorf("parse manifest %s: %w", path, err)
+    }
+    return &m, nil
+}
+
+// VerifyManifest compares the tree under root with m. A file is changed when its size,
+// mode or hash differs, with hashes compared case-insensitively so manifests written by
+// other tools in uppercase hex still match; files on disk that are not listed are
+// reported as extra.
+func VerifyManifest(root string, m *Manifest) (*ManifestDiff, error) {
+    current, err := GenerateManifest(root, m.Algorithm)
+    if err != nil {
+        return nil, err
+    }
+
+    onDisk := make(map[string]ManifestEntry, len(current.Files))
+    for _, entry := range current.Files {
+        onDisk[entry.Path] = entry
+    }
+
+    diff := &ManifestDiff{}
+    for _, want := range m.Files {
+        got, ok := onDisk[want.Path]
+        if !ok {
+            diff.Missing = append(diff.Missing, want.Path)
+            continue
+        }
+        delete(onDisk, want.Path)
+        if got.Size != want.Size || got.Mode != want.Mode || !strings.EqualFold(got.Hash, want.Hash) {
+            diff.Changed = append(diff.Changed, want.Path)
+        }
+    }
+    for path := range onDisk {
+        diff.Extra = append(diff.Extra, path)
+    }
+    sort.Strings(diff.Extra)
+    return diff, nil
+}
+
+// OK reports whether the tree matched the manifest exactly.
+func (d *ManifestDiff) OK() bool {
+    return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Changed) == 0
+}
+
+// manifestEntry builds the manifest entry for a single file.
+func manifestEntry(root, path string, algo HashAlgorithm) (ManifestEntry, error) {
+    info, err := os.Lstat(path)
+    if err != nil {
+        return ManifestEntry{}, err
+    }
+    sum, err := HashFile(path, algo)
+    if err != nil {
+        return ManifestEntry{}, err
+    }
+    rel, err := filepath.Rel(root, path)
+    if err != nil {
+        return ManifestEntry{}, err
+    }
+    return ManifestEntry{
+        Path: filepath.ToSlash(rel),
+        Size: info.Size(),
+        Mode: fmt.Sprintf("%04o", info.Mode().Perm()),
+        Hash: sum,
+    }, nil
+}
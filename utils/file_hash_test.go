@@ -0,0 +1,43 @@
+package utils
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// TestVerifyManifestUppercaseHex checks that a manifest with uppercase hex digests, as
+// some tools write them, matches, and that a real change is still detected.
+func TestVerifyManifestUppercaseHex(t *testing.T) {
+    root := t.TempDir()
+    if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    m, err := GenerateManifest(root, HashSHA256)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := range m.Files {
+        m.Files[i].Hash = strings.ToUpper(m.Files[i].Hash)
+    }
+
+    diff, err := VerifyManifest(root, m)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !diff.OK() {
+        t.Fatalf("uppercase manifest reported %+v", diff)
+    }
+
+    if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("HELLO"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    diff, err = VerifyManifest(root, m)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(diff.Changed) != 1 || diff.Changed[0] != "a.txt" {
+        t.Fatalf("changed file not detected: %+v", diff)
+    }
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+)
+
+// DefaultMaxLineLength is the line length limit used when LineReaderOptions leaves it unset.
+const DefaultMaxLineLength = 1024 * 1024
+
+// ErrLineTooLong is returned (wrapped) when a line exceeds the configured maximum length.
+var ErrLineTooLong = errors.New("line exceeds maximum length")
+
+// Line is a single line read by a LineReader, without its trailing "\n" or "\r\n".
+// Offset and End are byte offsets in the (decompressed) stream; End is where the next
+// line starts, so it can be stored and passed back as StartOffset to resume.
+type Line struct {
+    Number int64
+    Offset int64
+    End    int64
+    Text   string
+}
+
+// LineReaderOptions configures a LineReader. StartLine is the number given to the line
+// just before StartOffset, so numbering carries on when resuming.
+type LineReaderOptions struct {
+    MaxLineLength int
+    StartOffset   int64
+    StartLine     int64
+}
+
+// LineReader reads lines one at a time with a bounded line length. Gzip-compressed
+// input is detected and decompressed transparently.
+type LineReader struct {
+    r      *bufio.Reader
+    closer io.Closer
+    max    int
+    offset int64
+    line   int64
+}
+
+// NewLineReader returns a LineReader over r. If r is gzip-compressed it is decompressed;
+// otherwise, if r is an io.Seeker, it is positioned directly at opts.StartOffset.
+func NewLineReader(r io.Reader, opts LineReaderOptions) (*LineReader, error) {
+    if opts.MaxLineLength <= 0 {
+        opts.MaxLineLength = DefaultMaxLineLength
+    }
+
+    br := bufio.NewReader(r)
+    lr := &LineReader{max: opts.MaxLineLength, line: opts.StartLine}
+
+    magic, _ := br.Peek(2)
+    if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+        zr, err := gzip.NewReader(br)
+        if err != nil {
+            return nil, err
+        }
+        lr.closer = zr
+        br = bufio.NewReader(zr)
+    } else if seeker, ok := r.(io.Seeker); ok && opts.StartOffset > 0 {
+        if _, err := seeker.Seek(opts.StartOffset, io.SeekStart); err != nil {
+            return nil, err
+        }
+        br.Reset(r)
+        lr.offset = opts.StartOffset
+    }
+    lr.r = br
+
+    if skip := opts.StartOffset - lr.offset; skip > 0 {
+        n, err := io.CopyN(io.Discard, lr.r, skip)
+        lr.offset += n
+        if err != nil && err != io.EOF {
+            return nil, err
+        }
+    }
+    return lr, nil
+}
+
+// OpenLineReader opens a file and returns a LineReader over it. Close releases the file.
+func OpenLineReader(path string, opts LineReaderOptions) (*LineReader, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    return newFileLineReader(f, opts)
+}
+
+// newFileLineReader returns a LineReader over f whose Close also closes f.
+func newFileLineReader(f io.ReadCloser, opts LineReaderOptions) (*LineReader, error) {
+    lr, err := NewLineReader(f, opts)
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    if lr.closer != nil {
+        lr.closer = multiCloser{lr.closer, f}
+    } else {
+        lr.closer = f
+    }
+    return lr, nil
+}
+
+// Next returns the next line, or io.EOF when the input is exhausted.
+// A final line without a trailing newline is still returned.
+func (lr *LineReader) Next() (Line, error) {
+    var buf []byte
+    for {
+        chunk, err := lr.r.ReadSlice('\n')
+        buf = append(buf, chunk...)
+        // Allow room for the "\r\n" terminator before declaring the line too long.
+        if len(buf) > lr.max+2 {
+            return Line{}, fmt.Errorf("line %d at offset %d: %w", lr.line+1, lr.offset, ErrLineTooLong)
+        }
+        if err == bufio.ErrBufferFull {
+            continue
+        }
+        if err == io.EOF {
+            if len(buf) == 0 {
+                return Line{}, io.EOF
+            }
+            break
+        }
+        if err != nil {
+            return Line{}, err
+        }
+        break
+    }
+
+    raw := len(buf)
+    buf = bytes.TrimSuffix(buf, []byte("\n"))
+    buf = bytes.TrimSuffix(buf, []byte("\r"))
+    if len(buf) > lr.max {
+        return Line{}, fmt.Errorf("line %d at offset %d: %w", lr.line+1, lr.offset, ErrLineTooLong)
+    }
+
+    lr.line++
+    line := Line{Number: lr.line, Offset: lr.offset, End: lr.offset + int64(raw), Text: string(buf)}
+    lr.offset = line.End
+    return line, nil
+}
+
+// Offset returns the byte offset at which the next line starts.
+func (lr *LineReader) Offset() int64 {
+    return lr.offset
+}
+
+// Close releases any file or decompressor held by the reader.
+func (lr *LineReader) Close() error {
+    if lr.closer == nil {
+        return nil
+    }
+    return lr.closer.Close()
+}
+
+// ReadLinesFunc streams a file line-by-line, calling fn for each line without loading the
+// whole file into memory. Returning an error from fn stops the read and returns that error.
+func ReadLinesFunc(path string, opts LineReaderOptions, fn func(Line) error) error {
+    lr, err := OpenLineReader(path, opts)
+    if err != nil {
+        return err
+    }
+    return eachLine(lr, fn)
+}
+
+// eachLine calls fn for every line of lr, then closes it.
+func eachLine(lr *LineReader, fn func(Line) error) error {
+    defer lr.Close()
+
+    for {
+        line, err := lr.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if err := fn(line); err != nil {
+            return err
+        }
+    }
+}
+
+// multiCloser closes every closer in order and returns the first error.
+type multiCloser []io.Closer
+
+// Close implements io.Closer.
+func (m multiCloser) Close() error {
+    var first error
+    for _, c := range m {
+        if err := c.Close(); err != nil && first == nil {
+            first = err
+        }
+    }
+    return first
+}
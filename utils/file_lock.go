@@ -0,0 +1,227 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// LockMode selects a shared (reader) or exclusive (writer) advisory lock.
+type LockMode string
+
+const (
+    LockShared    LockMode = "shared"
+    LockExclusive LockMode = "exclusive"
+)
+
+// lockRetryInterval is how often a blocked lock attempt is retried.
+const lockRetryInterval = 50 * time.Millisecond
+
+// pidReadAttempts and pidReadInterval bound how long AcquirePIDLock waits for a holder
+// that has taken the lock but not yet written its PID.
+const (
+    pidReadAttempts = 10
+    pidReadInterval = 10 * time.Millisecond
+)
+
+// errLockBusy is returned by tryLockFile when another process holds a conflicting lock.
+var errLockBusy = errors.New("file is locked by another process")
+
+// LockHeldError is returned by AcquirePIDLock when a live process already holds the lock.
+// PID is 0 if the holder had not recorded its PID yet.
+type LockHeldError struct {
+    Path string
+    PID  int
+}
+
+// Error implements the error interface.
+func (e *LockHeldError) Error() string {
+    if e.PID == 0 {
+        return fmt.Sprintf("lock %s is held by an unknown pid", e.Path)
+    }
+    return fmt.Sprintf("lock %s is held by pid %d", e.Path, e.PID)
+}
+
+// FileLock is an advisory flock held on a file. It is released by Unlock or when the
+// process exits, and only coordinates processes that also use these helpers.
+type FileLock struct {
+    file *os.File
+    path string
+}
+
+// LockFile acquires a shared or exclusive lock on path, creating the file if needed,
+// and waits until the lock is granted or ctx is done.
+func LockFile(ctx context.Context, path string, mode LockMode) (*FileLock, error) {
+    f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+    if err != nil {
+        return nil, err
+    }
+
+    for {
+        err := tryLockFile(f, mode == LockExclusive)
+        if err == nil {
+            return &FileLock{file: f, path: path}, nil
+        }
+        if !errors.Is(err, errLockBusy) {
+            f.Close()
+            return nil, err
+        }
+
+        select {
+        case <-ctx.Done():
+            f.Close()
+            return nil, fmt.Errorf("lock %s: %w", path, ctx.Err())
+        case <-time.After(lockRetryInterval):
+        }
+    }
+}
+
+// LockFileTimeout acquires a lock on path, giving up after timeout.
+func LockFileTimeout(path string, mode LockMode, timeout time.Duration) (*FileLock, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    return LockFile(ctx, path, mode)
+}
+
+// Path returns the path of the locked file.
+func (l *FileLock) Path() string {
+    return l.path
+}
+
+// Unlock releases the lock and closes the file.
+func (l *FileLock) Unlock() error {
+    if err := unlockFile(l.file); err != nil {
+        l.file.Close()
+        return err
+    }
+    return l.file.Close()
+}
+
+// WithLockedFile performs a read-modify-write of path under an exclusive lock. fn receives
+// the current content (nil if the file does not exist) and returns the new content, which
+// is written atomically; returning nil data leaves the file untouched. The lock is taken on
+// path+".lock" because the atomic rename replaces the file itself.
+func WithLockedFile(ctx context.Context, path string, fn func(data []byte) ([]byte, error)) error {
+    lock, err := LockFile(ctx, path+".lock", LockExclusive)
+    if err != nil {
+        return err
+    }
+    defer lock.Unlock()
+
+    data, err := os.ReadFile(path)
+    if err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+
+    updated, err := fn(data)
+    if err != nil || updated == nil {
+        return err
+    }
+    return WriteFileAtomic(path, string(updated))
+}
+
+// PIDLock is a lock file holding the PID of the process that owns it.
+// StalePID is set when the lock was taken over from a process that had died.
+type PIDLock struct {
+    lock     *FileLock
+    StalePID int
+}
+
+// AcquirePIDLock takes an exclusive lock on path and writes the current PID into it.
+// If another live process holds it, a *LockHeldError is returned. A lock file left by a
+// dead process is detected and taken over.
+func AcquirePIDLock(path string) (*PIDLock, error) {
+    for {
+        f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+        if err != nil {
+            return nil, err
+        }
+
+        if err := tryLockFile(f, true); err != nil {
+            f.Close()
+            if errors.Is(err, errLockBusy) {
+                return nil, &LockHeldError{Path: path, PID: readHolderPID(path)}
+            }
+            return nil, err
+        }
+
+        // The previous owner may have removed the file between our open and lock;
+        // if so we locked an orphaned inode and must start again.
+        held, err := f.Stat()
+        if err != nil {
+            f.Close()
+            return nil, err
+        }
+        current, err := os.Stat(path)
+        if err != nil || !os.SameFile(held, current) {
+            f.Close()
+            continue
+        }
+
+        pl := &PIDLock{lock: &FileLock{file: f, path: path}}
+        if old, err := ReadPIDFile(path); err == nil && old != os.Getpid() && !processAlive(old) {
+            pl.StalePID = old
+        }
+
+        if err := f.Truncate(0); err != nil {
+            pl.lock.Unlock()
+            return nil, err
+        }
+        if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+            pl.lock.Unlock()
+            return nil, err
+        }
+        if err := f.Sync(); err != nil {
+            pl.lock.Unlock()
+            return nil, err
+        }
+        return pl, nil
+    }
+}
+
+// Release removes the lock file and releases the lock.
+func (p *PIDLock) Release() error {
+    if err := os.Remove(p.lock.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        p.lock.Unlock()
+        return err
+    }
+    return p.lock.Unlock()
+}
+
+// readHolderPID reads the PID of the process holding the lock at path. The holder writes
+// it just after locking, so an empty or partial file is retried briefly; 0 means unknown.
+func readHolderPID(path string) int {
+    for attempt := 1; ; attempt++ {
+        pid, err := ReadPIDFile(path)
+        if err == nil && pid > 0 {
+            return pid
+        }
+        if attempt >= pidReadAttempts {
+            return 0
+        }
+        time.Sleep(pidReadInterval)
+    }
+}
+
+// ReadPIDFile returns the PID recorded in a lock file.
+func ReadPIDFile(path string) (int, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return 0, err
+    }
+    return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// IsPIDLockStale reports whether the lock file at path names a process that no longer exists.
+func IsPIDLockStale(path string) (bool, error) {
+    pid, err := ReadPIDFile(path)
+    if err != nil {
+        return false, err
+    }
+    return !processAlive(pid), nil
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// skipWithoutLocks skips tests on platforms where tryLockFile is not supported.
+func skipWithoutLocks(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("file locking is not supported on windows")
+    }
+}
+
+// TestLockFileSharedExclusive checks that shared locks coexist and exclude writers.
+func TestLockFileSharedExclusive(t *testing.T) {
+    skipWithoutLocks(t)
+    path := filepath.Join(t.TempDir(), "data.lock")
+
+    r1, err := LockFileTimeout(path, LockShared, time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    r2, err := LockFileTimeout(path, LockShared, time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := LockFileTimeout(path, LockExclusive, 100*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("exclusive while shared is held: got %v, want a timeout", err)
+    }
+
+    r1.Unlock()
+    r2.Unlock()
+    w, err := LockFileTimeout(path, LockExclusive, time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := LockFileTimeout(path, LockShared, 100*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("shared while exclusive is held: got %v, want a timeout", err)
+    }
+    w.Unlock()
+}
+
+// TestLockFileCancel checks that a waiting LockFile returns when its context is cancelled.
+func TestLockFileCancel(t *testing.T) {
+    skipWithoutLocks(t)
+    path := filepath.Join(t.TempDir(), "data.lock")
+
+    held, err := LockFile(context.Background(), path, LockExclusive)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer held.Unlock()
+
+    ctx, cancel := context.WithCancel(context.Background())
+    time.AfterFunc(50*time.Millisecond, cancel)
+    if _, err := LockFile(ctx, path, LockExclusive); !errors.Is(err, context.Canceled) {
+        t.Fatalf("got %v, want context.Canceled", err)
+    }
+}
+
+// TestWithLockedFile checks that concurrent read-modify-write cycles do not lose updates.
+func TestWithLockedFile(t *testing.T) {
+    skipWithoutLocks(t)
+    path := filepath.Join(t.TempDir(), "counter")
+
+    const workers = 20
+    var wg sync.WaitGroup
+    errs := make(chan error, workers)
+    for i := 0; i < workers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            errs <- WithLockedFile(context.Background(), path, func(data []byte) ([]byte, error) {
+                n, _ := strconv.Atoi(string(data))
+                return []byte(strconv.Itoa(n + 1)), nil
+            })
+        }()
+    }
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(data) != strconv.Itoa(workers) {
+        t.Fatalf("counter = %s, want %d", data, workers)
+    }
+}
+
+// TestAcquirePIDLockHeld checks that a held PID lock reports its holder.
+func TestAcquirePIDLockHeld(t *testing.T) {
+    skipWithoutLocks(t)
+    path := filepath.Join(t.TempDir(), "app.pid")
+
+    pl, err := AcquirePIDLock(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, err = AcquirePIDLock(path)
+    var held *LockHeldError
+    if !errors.As(err, &held) || held.PID != os.Getpid() {
+        t.Fatalf("got %v, want LockHeldError for pid %d", err, os.Getpid())
+    }
+
+    if err := pl.Release(); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(path); !os.IsNotExist(err) {
+        t.Fatalf("lock file still exists after Release: %v", err)
+    }
+}
+
+// TestAcquirePIDLockUnknownHolder checks that a holder that has not written its PID is
+// reported as unknown rather than as pid 0.
+func TestAcquirePIDLockUnknownHolder(t *testing.T) {
+    skipWithoutLocks(t)
+    path := filepath.Join(t.TempDir(), "app.pid")
+
+    held, err := LockFile(context.Background(), path, LockExclusive)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer held.Unlock()
+
+    _, err = AcquirePIDLock(path)
+    var heldErr *LockHeldError
+    if !errors.As(err, &heldErr) || heldErr.PID != 0 || !strings.Contains(err.Error(), "unknown pid") {
+        t.Fatalf("got %v, want a LockHeldError with an unknown pid", err)
+    }
+}
+
+// TestAcquirePIDLockStale checks that a lock file left by a dead process is taken over.
+func TestAcquirePIDLockStale(t *testing.T) {
+    skipWithoutLocks(t)
+    path := filepath.Join(t.TempDir(), "app.pid")
+
+    dead := exec.Command("sh", "-c", "exit 0")
+    if err := dead.Run(); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(path, []byte(strconv.Itoa(dead.Process.Pid)+"\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if stale, err := IsPIDLockStale(path); err != nil || !stale {
+        t.Fatalf("IsPIDLockStale = %v, %v; want true", stale, err)
+    }
+
+    pl, err := AcquirePIDLock(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer pl.Release()
+    if pl.StalePID != dead.Process.Pid {
+        t.Fatalf("StalePID = %d, want %d", pl.StalePID, dead.Process.Pid)
+    }
+    if pid, err := ReadPIDFile(path); err != nil || pid != os.Getpid() {
+        t.Fatalf("lock file holds %d, %v; want %d", pid, err, os.Getpid())
+    }
+}
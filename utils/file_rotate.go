@@ -0,0 +1,279 @@
+package utils
+
+import (
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultMaxBackups is the number of rotated files kept when RotateOptions leaves it unset.
+const DefaultMaxBackups = 5
+
+// RotateOptions configures a RotatingWriter. A file is rotated once writing would take it
+// past MaxSize bytes, or once Interval has passed since the current file was started; zero
+// disables either trigger. With Interval set, the start of the interval is kept in a
+// path.period sidecar file, so restarting the process does not restart the interval.
+// Rotated files are named path.1 (newest) to path.N, with ".gz" appended when Compress is set.
+type RotateOptions struct {
+    MaxSize    int64
+    Interval   time.Duration
+    MaxBackups int
+    Compress   bool
+    Perm       os.FileMode
+}
+
+// RotatingWriter is an io.Writer that appends to a file and rotates it by size and/or age.
+// It is safe for concurrent use.
+type RotatingWriter struct {
+    mu        sync.Mutex
+    path      string
+    opts      RotateOptions
+    file      *os.File
+    size      int64
+    startedAt time.Time
+}
+
+// rotatingWriters holds the writers shared by AppendToFileRotating, keyed by path.
+var (
+    rotatingWritersMu sync.Mutex
+    rotatingWriters   = make(map[string]*RotatingWriter)
+)
+
+// NewRotatingWriter opens (or creates) path for appending with the given rotation options.
+func NewRotatingWriter(path string, opts RotateOptions) (*RotatingWriter, error) {
+    if opts.MaxBackups <= 0 {
+        opts.MaxBackups = DefaultMaxBackups
+    }
+    if opts.Perm == 0 {
+        opts.Perm = 0644
+    }
+
+    w := &RotatingWriter{path: path, opts: opts}
+    if err := w.open(); err != nil {
+        return nil, err
+    }
+    return w, nil
+}
+
+// SharedRotatingWriter returns the process-wide RotatingWriter for path, creating it with
+// opts on first use. Later calls for the same path ignore opts.
+func SharedRotatingWriter(path string, opts RotateOptions) (*RotatingWriter, error) {
+    rotatingWritersMu.Lock()
+    defer rotatingWritersMu.Unlock()
+
+    if w, ok := rotatingWriters[path]; ok {
+        return w, nil
+    }
+    w, err := NewRotatingWriter(path, opts)
+    if err != nil {
+        return nil, err
+    }
+    rotatingWriters[path] = w
+    return w, nil
+}
+
+// AppendToFileRotating appends content to a file like AppendToFile, rotating it according
+// to opts. All callers appending to the same path share one writer.
+func AppendToFileRotating(path string, content string, opts RotateOptions) error {
+    w, err := SharedRotatingWriter(path, opts)
+    if err != nil {
+        return err
+    }
+    _, err = io.WriteString(w, content)
+    return err
+}
+
+// Write appends p to the current file, rotating first if p would exceed MaxSize or the
+// file has reached its Interval.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.file == nil {
+        if err := w.open(); err != nil {
+            return 0, err
+        }
+    }
+    if w.shouldRotate(int64(len(p))) {
+        if err := w.rotate(); err != nil {
+            return 0, err
+        }
+    }
+
+    n, err := w.file.Write(p)
+    w.size += int64(n)
+    return n, err
+}
+
+// Rotate forces a rotation regardless of size and age.
+func (w *RotatingWriter) Rotate() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.rotate()
+}
+
+// Sync flushes the current file to disk.
+func (w *RotatingWriter) Sync() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.file == nil {
+        return nil
+    }
+    return w.file.Sync()
+}
+
+// Close closes the current file. A later Write reopens it.
+func (w *RotatingWriter) Close() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.file == nil {
+        return nil
+    }
+    err := w.file.Close()
+    w.file = nil
+    return err
+}
+
+// shouldRotate reports whether writing n more bytes requires a rotation first.
+// An empty file is never rotated, so a single oversized write still lands somewhere.
+func (w *RotatingWriter) shouldRotate(n int64) bool {
+    if w.size == 0 {
+        return false
+    }
+    if w.opts.MaxSize > 0 && w.size+n > w.opts.MaxSize {
+        return true
+    }
+    return w.opts.Interval > 0 && time.Since(w.startedAt) >= w.opts.Interval
+}
+
+// open opens the active file for appending and records its current size and start time.
+func (w *RotatingWriter) open() error {
+    f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, w.opts.Perm)
+    if err != nil {
+        return err
+    }
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return err
+    }
+
+    w.file = f
+    w.size = info.Size()
+    w.startedAt = time.Now()
+    if w.opts.Interval > 0 {
+        if err := w.loadPeriod(); err != nil {
+            f.Close()
+            w.file = nil
+            return err
+        }
+    }
+    return nil
+}
+
+// periodFile returns the name of the sidecar file that records when the active file's
+// rotation interval started.
+func (w *RotatingWriter) periodFile() string {
+    return w.path + ".period"
+}
+
+// loadPeriod restores the interval start of a non-empty active file from the sidecar.
+// An empty file, or one without a readable sidecar, starts a new interval now, which is
+// recorded for the next process.
+func (w *RotatingWriter) loadPeriod() error {
+    if w.size > 0 {
+        if data, err := os.ReadFile(w.periodFile()); err == nil {
+            if started, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data))); err == nil {
+                w.startedAt = started
+                return nil
+            }
+        }
+    }
+    return os.WriteFile(w.periodFile(), []byte(w.startedAt.Format(time.RFC3339Nano)+"\n"), w.opts.Perm)
+}
+
+// rotate shifts existing backups up by one, moves the active file to path.1 and reopens.
+func (w *RotatingWriter) rotate() error {
+    if w.file != nil {
+        if err := w.file.Close(); err != nil {
+            return err
+        }
+        w.file = nil
+    }
+
+    for i := w.opts.MaxBackups; i >= 1; i-- {
+        for _, suffix := range []string{"", ".gz"} {
+            src := w.backupName(i) + suffix
+            if _, err := os.Stat(src); err != nil {
+                continue
+            }
+            if i == w.opts.MaxBackups {
+                if err := os.Remove(src); err != nil {
+                    return err
+                }
+                continue
+            }
+            if err := os.Rename(src, w.backupName(i+1)+suffix); err != nil {
+                return err
+            }
+        }
+    }
+
+    if err := os.Rename(w.path, w.backupName(1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    if w.opts.Compress {
+        if err := gzipFile(w.backupName(1)); err != nil {
+            return err
+        }
+    }
+    return w.open()
+}
+
+// backupName returns the name of the i-th rotated file, without any ".gz" suffix.
+func (w *RotatingWriter) backupName(i int) string {
+    return fmt.Sprintf("%s.%d", w.path, i)
+}
+
+// gzipFile compresses path to path.gz and removes the original.
+func gzipFile(path string) error {
+    src, err := os.Open(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    defer src.Close()
+
+    info, err := src.Stat()
+    if err != nil {
+        return err
+    }
+    dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+    if err != nil {
+        return err
+    }
+
+    zw := gzip.NewWriter(dst)
+    if _, err := io.Copy(zw, src); err != nil {
+        dst.Close()
+        return err
+    }
+    if err := zw.Close(); err != nil {
+        dst.Close()
+        return err
+    }
+    if err := dst.Close(); err != nil {
+        return err
+    }
+    return os.Remove(path)
+}
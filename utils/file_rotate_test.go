@@ -0,0 +1,81 @@
+package utils
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// TestRotatingWriterIntervalSurvivesReopen checks that the rotation interval of an existing
+// file is measured from the recorded start of its period, not from when the writer opened it.
+func TestRotatingWriterIntervalSurvivesReopen(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "app.log")
+    if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    started := time.Now().Add(-2 * time.Hour).Format(time.RFC3339Nano)
+    if err := os.WriteFile(path+".period", []byte(started+"\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    w, err := NewRotatingWriter(path, RotateOptions{Interval: time.Hour})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer w.Close()
+    if _, err := w.Write([]byte("new\n")); err != nil {
+        t.Fatal(err)
+    }
+
+    if data, err := os.ReadFile(path + ".1"); err != nil || string(data) != "old\n" {
+        t.Fatalf("backup = %q, %v; want old contents", data, err)
+    }
+    if data, err := os.ReadFile(path); err != nil || string(data) != "new\n" {
+        t.Fatalf("active file = %q, %v; want new contents", data, err)
+    }
+}
+
+// TestRotatingWriterIntervalIgnoresModTime checks that an old modification time is not
+// mistaken for the start of the period, and that the start a writer records is kept
+// across reopening.
+func TestRotatingWriterIntervalIgnoresModTime(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "app.log")
+    if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    past := time.Now().Add(-2 * time.Hour)
+    if err := os.Chtimes(path, past, past); err != nil {
+        t.Fatal(err)
+    }
+
+    w, err := NewRotatingWriter(path, RotateOptions{Interval: time.Hour})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := w.Write([]byte("new\n")); err != nil {
+        t.Fatal(err)
+    }
+    recorded, err := os.ReadFile(path + ".period")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := w.Close(); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(path + ".1"); err == nil {
+        t.Fatal("rotated on the file's modification time")
+    }
+
+    w, err = NewRotatingWriter(path, RotateOptions{Interval: time.Hour})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer w.Close()
+    if _, err := w.Write([]byte("more\n")); err != nil {
+        t.Fatal(err)
+    }
+    if again, err := os.ReadFile(path + ".period"); err != nil || string(again) != string(recorded) {
+        t.Fatalf("period start changed on reopen: %q -> %q (%v)", recorded, again, err)
+    }
+}
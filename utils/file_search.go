@@ -0,0 +1,419 @@
+package utils
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// SearchType restricts which kinds of entries a search returns.
+type SearchType string
+
+const (
+    SearchFilesOnly    SearchType = "file"
+    SearchDirsOnly     SearchType = "dir"
+    SearchSymlinksOnly SearchType = "symlink"
+    SearchAnyType      SearchType = "any"
+)
+
+// SearchOptions configures SearchFiles and WalkFiles.
+//
+// Extensions (case-insensitive, with or without the dot, e.g. "json" or ".tar.gz"), Globs
+// (matched against the base name or the slash-separated path relative to root) and Regexps
+// (matched against the relative path) each only apply when non-empty: an entry must match at
+// least one value from every group that is set. Type defaults to regular files. MaxDepth 1
+// means only root's direct children; 0 is unlimited. IgnoreFiles names per-directory files
+// such as ".gitignore" whose patterns exclude entries, and Exclude adds patterns of the same
+// syntax anchored at root. With SkipUnreadable, directories that cannot be read are
+// reported as warnings instead of aborting the search. With FollowSymlinks, links are
+// matched and descended by their targets; a dangling link is always just a warning and
+// is treated as the link itself.
+type SearchOptions struct {
+    Extensions     []string
+    Globs          []string
+    Regexps        []string
+    Type           SearchType
+    MinSize        int64
+    MaxSize        int64
+    ModifiedAfter  time.Time
+    ModifiedBefore time.Time
+    MaxDepth       int
+    FollowSymlinks bool
+    IgnoreFiles    []string
+    Exclude        []string
+    SkipUnreadable bool
+}
+
+// SearchWarning describes a path that was skipped during a search.
+type SearchWarning struct {
+    Path string
+    Err  error
+}
+
+// SearchFiles recursively searches root and returns every matching path.
+func SearchFiles(ctx context.Context, root string, opts SearchOptions) ([]string, []SearchWarning, error) {
+    var matched []string
+    warnings, err := WalkFiles(ctx, root, opts, func(path string, info fs.FileInfo) error {
+        matched = append(matched, path)
+        return nil
+    })
+    return matched, warnings, err
+}
+
+// WalkFiles recursively searches root and streams each match to fn as it is found, so huge
+// trees never need to be held in memory. Returning an error from fn stops the walk.
+func WalkFiles(ctx context.Context, root string, opts SearchOptions, fn func(path string, info fs.FileInfo) error) ([]SearchWarning, error) {
+    s, err := newSearcher(root, opts, fn)
+    if err != nil {
+        return nil, err
+    }
+
+    info, err := os.Stat(root)
+    if err != nil {
+        return nil, err
+    }
+
+    var rootRules []ignoreRule
+    for _, pattern := range opts.Exclude {
+        if rule, ok := parseIgnoreRule(pattern, ""); ok {
+            rootRules = append(rootRules, rule)
+        }
+    }
+
+    err = s.walk(ctx, root, 1, rootRules, []os.FileInfo{info})
+    return s.warnings, err
+}
+
+// searcher holds the compiled state of a single search.
+type searcher struct {
+    root     string
+    opts     SearchOptions
+    exts     []string
+    regexps  []*regexp.Regexp
+    fn       func(string, fs.FileInfo) error
+    warnings []SearchWarning
+}
+
+// newSearcher validates opts and compiles its patterns.
+func newSearcher(root string, opts SearchOptions, fn func(string, fs.FileInfo) error) (*searcher, error) {
+    if opts.Type == "" {
+        opts.Type = SearchFilesOnly
+    }
+    s := &searcher{root: root, opts: opts, fn: fn}
+
+    for _, ext := range opts.Extensions {
+        ext = strings.ToLower(ext)
+        if !strings.HasPrefix(ext, ".") {
+            ext = "." + ext
+        }
+        s.exts = append(s.exts, ext)
+    }
+    for _, pattern := range opts.Globs {
+        if _, err := filepath.Match(pattern, ""); err != nil {
+            return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+        }
+    }
+    for _, pattern := range opts.Regexps {
+        re, err := regexp.Compile(pattern)
+        if err != nil {
+            return nil, fmt.Errorf("invalid regexp %q: %w", pattern, err)
+        }
+        s.regexps = append(s.regexps, re)
+    }
+    return s, nil
+}
+
+// walk visits the entries of dir. ancestors holds the directories on the current path,
+// used to detect symlink loops.
+func (s *searcher) walk(ctx context.Context, dir string, depth int, rules []ignoreRule, ancestors []os.FileInfo) error {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return s.warn(dir, err)
+    }
+
+    rel := s.rel(dir)
+    for _, name := range s.opts.IgnoreFiles {
+        loaded, err := loadIgnoreFile(filepath.Join(dir, name), rel)
+        if err != nil {
+            if err := s.warn(filepath.Join(dir, name), err); err != nil {
+                return err
+            }
+            continue
+        }
+        rules = append(rules, loaded...)
+    }
+
+    for _, entry := range entries {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+
+        path := filepath.Join(dir, entry.Name())
+        info, err := entry.Info()
+        if err != nil {
+            if err := s.warn(path, err); err != nil {
+                return err
+            }
+            continue
+        }
+
+        // A followed link is judged by its target; a dangling one stays a plain link.
+        isLink := info.Mode()&fs.ModeSymlink != 0
+        followed := false
+        if isLink && s.opts.FollowSymlinks {
+            target, err := os.Stat(path)
+            if err != nil {
+                s.warnings = append(s.warnings, SearchWarning{Path: path, Err: err})
+            } else {
+                info, followed = target, true
+            }
+        }
+
+        relPath := s.rel(path)
+        if ignored(rules, relPath, info.IsDir()) {
+            continue
+        }
+
+        if s.matches(relPath, info, isLink, followed) {
+            if err := s.fn(path, info); err != nil {
+                return err
+            }
+        }
+
+        if (isLink && !followed) || !info.IsDir() || (s.opts.MaxDepth > 0 && depth >= s.opts.MaxDepth) {
+            continue
+        }
+        if loopsBack(info, ancestors) {
+            if err := s.warn(path, fmt.Errorf("symlink loop detected")); err != nil {
+                return err
+            }
+            continue
+        }
+        if err := s.walk(ctx, path, depth+1, rules, append(ancestors, info)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// warn records a skipped path, or returns err when unreadable entries are fatal.
+func (s *searcher) warn(path string, err error) error {
+    if !s.opts.SkipUnreadable {
+        return err
+    }
+    s.warnings = append(s.warnings, SearchWarning{Path: path, Err: err})
+    return nil
+}
+
+// rel returns path relative to the search root with forward slashes.
+func (s *searcher) rel(path string) string {
+    rel, err := filepath.Rel(s.root, path)
+    if err != nil || rel == "." {
+        return ""
+    }
+    return filepath.ToSlash(rel)
+}
+
+// matches applies the type, name, size and time filters. For a followed link, info
+// describes the target, so the link also counts as a file or directory.
+func (s *searcher) matches(relPath string, info fs.FileInfo, isLink, followed bool) bool {
+    unresolved := isLink && !followed
+    switch s.opts.Type {
+    case SearchFilesOnly:
+        if unresolved || !info.Mode().IsRegular() {
+            return false
+        }
+    case SearchDirsOnly:
+        if unresolved || !info.IsDir() {
+            return false
+        }
+    case SearchSymlinksOnly:
+        if !isLink {
+            return false
+        }
+    }
+
+    name := strings.ToLower(info.Name())
+    if len(s.exts) > 0 && !hasAnySuffix(name, s.exts) {
+        return false
+    }
+    if len(s.opts.Globs) > 0 && !matchAnyGlob(s.root, filepath.Join(s.root, filepath.FromSlash(relPath)), s.opts.Globs) {
+        return false
+    }
+    if len(s.regexps) > 0 && !matchAnyRegexp(relPath, s.regexps) {
+        return false
+    }
+
+    if !info.IsDir() {
+        if info.Size() < s.opts.MinSize {
+            return false
+        }
+        if s.opts.MaxSize > 0 && info.Size() > s.opts.MaxSize {
+            return false
+        }
+    }
+    if !s.opts.ModifiedAfter.IsZero() && !info.ModTime().After(s.opts.ModifiedAfter) {
+        return false
+    }
+    if !s.opts.ModifiedBefore.IsZero() && !info.ModTime().Before(s.opts.ModifiedBefore) {
+        return false
+    }
+    return true
+}
+
+// hasAnySuffix reports whether name ends with any of the suffixes.
+func hasAnySuffix(name string, suffixes []string) bool {
+    for _, suffix := range suffixes {
+        if strings.HasSuffix(name, suffix) {
+            return true
+        }
+    }
+    return false
+}
+
+// matchAnyRegexp reports whether s matches any of the expressions.
+func matchAnyRegexp(s string, regexps []*regexp.Regexp) bool {
+    for _, re := range regexps {
+        if re.MatchString(s) {
+            return true
+        }
+    }
+    return false
+}
+
+// loopsBack reports whether dir is one of its own ancestors, i.e. reached through a symlink loop.
+func loopsBack(dir os.FileInfo, ancestors []os.FileInfo) bool {
+    for _, a := range ancestors {
+        if os.SameFile(dir, a) {
+            return true
+        }
+    }
+    return false
+}
+
+// ignoreRule is one compiled line of a .gitignore-style file.
+type ignoreRule struct {
+    re      *regexp.Regexp
+    negate  bool
+    dirOnly bool
+}
+
+// loadIgnoreFile parses an ignore file whose patterns are relative to base.
+// A missing file yields no rules.
+func loadIgnoreFile(path, base string) ([]ignoreRule, error) {
+    f, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    var rules []ignoreRule
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        if rule, ok := parseIgnoreRule(scanner.Text(), base); ok {
+            rules = append(rules, rule)
+        }
+    }
+    return rules, scanner.Err()
+}
+
+// parseIgnoreRule compiles a gitignore pattern. Patterns containing a slash are anchored
+// to base; others match a name at any depth below it. "**", "!" and a trailing "/" are supported.
+func parseIgnoreRule(line, base string) (ignoreRule, bool) {
+    line = strings.TrimRight(line, " \t\r")
+    if line == "" || strings.HasPrefix(line, "#") {
+        return ignoreRule{}, false
+    }
+
+    var rule ignoreRule
+    if strings.HasPrefix(line, "!") {
+        rule.negate = true
+        line = line[1:]
+    }
+    if strings.HasSuffix(line, "/") {
+        rule.dirOnly = true
+        line = strings.TrimSuffix(line, "/")
+    }
+
+    anchored := strings.Contains(line, "/")
+    line = strings.TrimPrefix(line, "/")
+    if line == "" {
+        return ignoreRule{}, false
+    }
+
+    prefix := "^"
+    if base != "" {
+        prefix += regexp.QuoteMeta(base) + "/"
+    }
+    if !anchored {
+        prefix += "(?:.*/)?"
+    }
+
+    re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+    if err != nil {
+        return ignoreRule{}, false
+    }
+    rule.re = re
+    return rule, true
+}
+
+// globToRegexp translates gitignore glob syntax into a regular expression body.
+func globToRegexp(glob string) string {
+    var b strings.Builder
+    for i := 0; i < len(glob); i++ {
+        c := glob[i]
+        switch {
+        case strings.HasPrefix(glob[i:], "**/"):
+            b.WriteString("(?:.*/)?")
+            i += 2
+        case strings.HasPrefix(glob[i:], "**"):
+            b.WriteString(".*")
+            i++
+        case c == '*':
+            b.WriteString("[^/]*")
+        case c == '?':
+            b.WriteString("[^/]")
+        case c == '[':
+            end := strings.IndexByte(glob[i:], ']')
+            if end < 0 {
+                b.WriteString(`\[`)
+                continue
+            }
+            class := glob[i+1 : i+end]
+            if strings.HasPrefix(class, "!") {
+                class = "^" + class[1:]
+            }
+            b.WriteString("[" + class + "]")
+            i += end
+        case c == '\\' && i+1 < len(glob):
+            i++
+            b.WriteString(regexp.QuoteMeta(string(glob[i])))
+        default:
+            b.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+    return b.String()
+}
+
+// ignored applies rules in order; the last matching rule decides.
+func ignored(rules []ignoreRule, relPath string, isDir bool) bool {
+    result := false
+    for _, rule := range rules {
+        if rule.dirOnly && !isDir {
+            continue
+        }
+        if rule.re.MatchString(relPath) {
+            result = !rule.negate
+        }
+    }
+    return result
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "runtime"
+    "sort"
+    "strings"
+    "testing"
+)
+
+// writeTree creates the given files (relative, slash-separated) under root.
+func writeTree(t *testing.T, root string, files ...string) {
+    t.Helper()
+    for _, name := range files {
+        path := filepath.Join(root, filepath.FromSlash(name))
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+// searchRel runs SearchFiles and returns the matches relative to root, sorted.
+func searchRel(t *testing.T, root string, opts SearchOptions) ([]string, []SearchWarning) {
+    t.Helper()
+    matched, warnings, err := SearchFiles(context.Background(), root, opts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var rel []string
+    for _, path := range matched {
+        r, err := filepath.Rel(root, path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        rel = append(rel, filepath.ToSlash(r))
+    }
+    sort.Strings(rel)
+    return rel, warnings
+}
+
+// skipWithoutSymlinks skips tests that create symlinks where that needs privileges.
+func skipWithoutSymlinks(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("symlinks need extra privileges on windows")
+    }
+}
+
+// TestSearchFilesMaxDepth checks that MaxDepth 1 only returns root's direct children.
+func TestSearchFilesMaxDepth(t *testing.T) {
+    root := t.TempDir()
+    writeTree(t, root, "a.json", "sub/b.json", "sub/deeper/c.json")
+
+    got, _ := searchRel(t, root, SearchOptions{Extensions: []string{"json"}, MaxDepth: 1})
+    if strings.Join(got, ",") != "a.json" {
+        t.Fatalf("depth 1: got %v", got)
+    }
+    got, _ = searchRel(t, root, SearchOptions{Extensions: []string{"json"}, MaxDepth: 2})
+    if strings.Join(got, ",") != "a.json,sub/b.json" {
+        t.Fatalf("depth 2: got %v", got)
+    }
+    got, _ = searchRel(t, root, SearchOptions{Extensions: []string{".JSON"}})
+    if len(got) != 3 {
+        t.Fatalf("unlimited: got %v", got)
+    }
+}
+
+// TestSearchFilesIgnoreFiles checks per-directory ignore files, negation and Exclude.
+func TestSearchFilesIgnoreFiles(t *testing.T) {
+    root := t.TempDir()
+    writeTree(t, root, "keep.txt", "build/out.txt", "logs/a.log", "logs/keep.log", "sub/x.tmp", "sub/y.txt", "vendor/v.txt")
+    if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("# comment\nbuild/\n*.log\n!keep.log\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(root, "sub", ".gitignore"), []byte("*.tmp\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    got, _ := searchRel(t, root, SearchOptions{Globs: []string{"*.txt", "*.log", "*.tmp"}, IgnoreFiles: []string{".gitignore"}, Exclude: []string{"/vendor"}})
+    want := "keep.txt,logs/keep.log,sub/y.txt"
+    if strings.Join(got, ",") != want {
+        t.Fatalf("got %v, want %s", got, want)
+    }
+}
+
+// TestSearchFilesSymlinkLoop checks that a link back to an ancestor is reported once
+// and not descended into.
+func TestSearchFilesSymlinkLoop(t *testing.T) {
+    skipWithoutSymlinks(t)
+    root := t.TempDir()
+    writeTree(t, root, "sub/a.json")
+    if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); err != nil {
+        t.Fatal(err)
+    }
+
+    got, warnings := searchRel(t, root, SearchOptions{Extensions: []string{"json"}, FollowSymlinks: true, SkipUnreadable: true})
+    if strings.Join(got, ",") != "sub/a.json" {
+        t.Fatalf("got %v", got)
+    }
+    if len(warnings) != 1 || !strings.HasSuffix(warnings[0].Path, "loop") {
+        t.Fatalf("got warnings %v, want one for the loop", warnings)
+    }
+}
+
+// TestSearchFilesDanglingSymlink checks that a dangling link does not abort a search that
+// follows links, and that it still counts as a symlink.
+func TestSearchFilesDanglingSymlink(t *testing.T) {
+    skipWithoutSymlinks(t)
+    root := t.TempDir()
+    writeTree(t, root, "a.json", "target.json")
+    if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "dangling")); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Symlink(filepath.Join(root, "target.json"), filepath.Join(root, "link.json")); err != nil {
+        t.Fatal(err)
+    }
+
+    got, warnings := searchRel(t, root, SearchOptions{Extensions: []string{"json"}, FollowSymlinks: true})
+    if strings.Join(got, ",") != "a.json,link.json,target.json" {
+        t.Fatalf("files: got %v", got)
+    }
+    if len(warnings) != 1 || !errors.Is(warnings[0].Err, fs.ErrNotExist) {
+        t.Fatalf("got warnings %v, want one for the dangling link", warnings)
+    }
+
+    got, _ = searchRel(t, root, SearchOptions{Type: SearchSymlinksOnly, FollowSymlinks: true})
+    if strings.Join(got, ",") != "dangling,link.json" {
+        t.Fatalf("symlinks: got %v", got)
+    }
+}
+
+// TestSearchFilesUnreadableDir checks that SkipUnreadable turns an unreadable directory
+// into a warning, and that without it the search fails.
+func TestSearchFilesUnreadableDir(t *testing.T) {
+    if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+        t.Skip("needs a platform and user that honour directory permissions")
+    }
+    root := t.TempDir()
+    writeTree(t, root, "a.json", "locked/b.json")
+    locked := filepath.Join(root, "locked")
+    if err := os.Chmod(locked, 0); err != nil {
+        t.Fatal(err)
+    }
+    defer os.Chmod(locked, 0755)
+
+    got, warnings := searchRel(t, root, SearchOptions{Extensions: []string{"json"}, SkipUnreadable: true})
+    if strings.Join(got, ",") != "a.json" || len(warnings) != 1 || warnings[0].Path != locked {
+        t.Fatalf("got %v with warnings %v", got, warnings)
+    }
+    if _, _, err := SearchFiles(context.Background(), root, SearchOptions{Extensions: []string{"json"}}); !errors.Is(err, fs.ErrPermission) {
+        t.Fatalf("without SkipUnreadable: got %v, want a permission error", err)
+    }
+}
+
+// TestWalkFilesStreaming checks that matches are streamed and that an error from the
+// callback stops the walk.
+func TestWalkFilesStreaming(t *testing.T) {
+    root := t.TempDir()
+    writeTree(t, root, "a.txt", "b.txt", "c.txt", "sub/d.txt")
+
+    stop := errors.New("stop")
+    var seen int
+    _, err := WalkFiles(context.Background(), root, SearchOptions{}, func(path string, info fs.FileInfo) error {
+        seen++
+        if seen == 2 {
+            return stop
+        }
+        return nil
+    })
+    if !errors.Is(err, stop) || seen != 2 {
+        t.Fatalf("got %v after %d matches, want stop after 2", err, seen)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := WalkFiles(ctx, root, SearchOptions{}, func(string, fs.FileInfo) error { return nil }); !errors.Is(err, context.Canceled) {
+        t.Fatalf("cancelled walk: got %v", err)
+    }
+}
@@ -0,0 +1,80 @@
+//go:build !windows
+
+package utils
+
+import (
+    "errors"
+    "os"
+    "syscall"
+)
+
+// copyOwnership gives f the same owner and group as the file described by info. Only
+// privileged processes may give files away, so a permission error is not fatal.
+func copyOwnership(f *os.File, info os.FileInfo) error {
+    stat, ok := info.Sys().(*syscall.Stat_t)
+    if !ok {
+        return nil
+    }
+    current, err := f.Stat()
+    if err != nil {
+        return err
+    }
+    if cur, ok := current.Sys().(*syscall.Stat_t); ok && cur.Uid == stat.Uid && cur.Gid == stat.Gid {
+        return nil
+    }
+    if err := f.Chown(int(stat.Uid), int(stat.Gid)); err != nil && !errors.Is(err, syscall.EPERM) {
+        return err
+    }
+    return nil
+}
+
+// isCrossDevice reports whether err is a rename failing because src and dst are on
+// different filesystems.
+func isCrossDevice(err error) bool {
+    return errors.Is(err, syscall.EXDEV)
+}
+
+// syncDir fsyncs a directory so that a rename or create inside it is durable.
+func syncDir(dir string) error {
+    d, err := os.Open(dir)
+    if err != nil {
+        return err
+    }
+    defer d.Close()
+    return d.Sync()
+}
+
+// fileIdentity returns the inode number of the file described by info.
+func fileIdentity(info os.FileInfo) uint64 {
+    if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+        return uint64(stat.Ino)
+    }
+    return 0
+}
+
+// tryLockFile takes a non-blocking flock on f, returning errLockBusy if it is held elsewhere.
+func tryLockFile(f *os.File, exclusive bool) error {
+    how := syscall.LOCK_SH
+    if exclusive {
+        how = syscall.LOCK_EX
+    }
+    err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+    if errors.Is(err, syscall.EWOULDBLOCK) {
+        return errLockBusy
+    }
+    return err
+}
+
+// unlockFile releases a flock taken by tryLockFile.
+func unlockFile(f *os.File) error {
+    return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+    if pid <= 0 {
+        return false
+    }
+    err := syscall.Kill(pid, 0)
+    return err == nil || errors.Is(err, syscall.EPERM)
+}
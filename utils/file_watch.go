@@ -0,0 +1,358 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// WatchOp is the kind of change reported by a DirWatcher.
+type WatchOp string
+
+const (
+    WatchCreated  WatchOp = "created"
+    WatchModified WatchOp = "modified"
+    WatchDeleted  WatchOp = "deleted"
+    WatchRenamed  WatchOp = "renamed"
+)
+
+// Default watcher timings used when WatchOptions leaves them unset.
+const (
+    DefaultWatchDebounce     = 100 * time.Millisecond
+    DefaultWatchPollInterval = 1 * time.Second
+)
+
+// ErrWatchOverflow is reported by DirWatcher.Err when the kernel event queue overflowed.
+// Events were lost, so the watcher stops rather than deliver an incomplete stream;
+// callers should rescan the tree and start a new watch.
+var ErrWatchOverflow = errors.New("watch event queue overflowed; events were lost")
+
+// errNativeWatchUnavailable is returned by watchNative on platforms without inotify.
+var errNativeWatchUnavailable = errors.New("native file watching is not available")
+
+// WatchEvent is a single, debounced change inside a watched tree.
+// OldPath is set for renames.
+type WatchEvent struct {
+    Op      WatchOp
+    Path    string
+    OldPath string
+    IsDir   bool
+    Time    time.Time
+}
+
+// WatchOptions configures WatchDir.
+//
+// Include and Exclude are filepath.Match globs tested against both the base name and the
+// slash-separated path relative to the root; an empty Include matches everything and
+// Exclude always wins. Excluded directories are not descended into. Events for the same
+// path within the Debounce window are coalesced into one.
+type WatchOptions struct {
+    Include      []string
+    Exclude      []string
+    Debounce     time.Duration
+    PollInterval time.Duration
+    ForcePolling bool
+}
+
+// DirWatcher reports changes in a directory tree, using inotify where available and
+// falling back to periodic polling otherwise. New subdirectories are watched automatically.
+type DirWatcher struct {
+    root    string
+    opts    WatchOptions
+    events  chan WatchEvent
+    polling bool
+
+    mu  sync.Mutex
+    err error
+}
+
+// WatchDir starts watching root recursively until ctx is done.
+// The Events channel is closed when watching stops; Err then reports any fatal error.
+func WatchDir(ctx context.Context, root string, opts WatchOptions) (*DirWatcher, error) {
+    if opts.Debounce <= 0 {
+        opts.Debounce = DefaultWatchDebounce
+    }
+    if opts.PollInterval <= 0 {
+        opts.PollInterval = DefaultWatchPollInterval
+    }
+
+    info, err := os.Stat(root)
+    if err != nil {
+        return nil, err
+    }
+    if !info.IsDir() {
+        return nil, errors.New("watch root is not a directory: " + root)
+    }
+
+    w := &DirWatcher{root: root, opts: opts, events: make(chan WatchEvent)}
+    raw := make(chan WatchEvent, 256)
+
+    if opts.ForcePolling {
+        w.polling = true
+    } else if err := watchNative(ctx, root, w.skipDir, raw, w.fail); err != nil {
+        w.polling = true
+    }
+    if w.polling {
+        snapshot, err := scanTree(root, w.skipDir)
+        if err != nil {
+            return nil, err
+        }
+        go watchPolling(ctx, root, snapshot, opts.PollInterval, w.skipDir, raw, w.fail)
+    }
+
+    go w.coalesce(ctx, raw)
+    return w, nil
+}
+
+// Events returns the channel on which debounced change events are delivered.
+func (w *DirWatcher) Events() <-chan WatchEvent {
+    return w.events
+}
+
+// Polling reports whether the watcher fell back to polling.
+func (w *DirWatcher) Polling() bool {
+    return w.polling
+}
+
+// Err returns the error that stopped the watcher, or nil if it stopped because ctx was done.
+func (w *DirWatcher) Err() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.err
+}
+
+// fail records the first fatal error.
+func (w *DirWatcher) fail(err error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if w.err == nil {
+        w.err = err
+    }
+}
+
+// matches reports whether path passes the include and exclude filters.
+func (w *DirWatcher) matches(path string) bool {
+    if matchAnyGlob(w.root, path, w.opts.Exclude) {
+        return false
+    }
+    return len(w.opts.Include) == 0 || matchAnyGlob(w.root, path, w.opts.Include)
+}
+
+// skipDir reports whether a subdirectory is excluded and should not be watched.
+func (w *DirWatcher) skipDir(path string) bool {
+    return path != w.root && matchAnyGlob(w.root, path, w.opts.Exclude)
+}
+
+// coalesce debounces raw events per path and forwards them until raw is closed.
+func (w *DirWatcher) coalesce(ctx context.Context, raw <-chan WatchEvent) {
+    defer close(w.events)
+
+    var order []string
+    pending := make(map[string]WatchEvent)
+    timer := time.NewTimer(time.Hour)
+    timer.Stop()
+    var oldest time.Time
+
+    flush := func() bool {
+        for _, path := range order {
+            select {
+            case w.events <- pending[path]:
+            case <-ctx.Done():
+                return false
+            }
+        }
+        order = order[:0]
+        pending = make(map[string]WatchEvent)
+        return true
+    }
+
+    for {
+        select {
+        case ev, ok := <-raw:
+            if !ok {
+                flush()
+                return
+            }
+            if !w.matches(ev.Path) {
+                continue
+            }
+            prev, seen := pending[ev.Path]
+            if !seen {
+                order = append(order, ev.Path)
+                if len(pending) == 0 {
+                    oldest = ev.Time
+                }
+                pending[ev.Path] = ev
+            } else if merged, keep := mergeWatchEvents(prev, ev); keep {
+                pending[ev.Path] = merged
+            } else {
+                delete(pending, ev.Path)
+                for i, path := range order {
+                    if path == ev.Path {
+                        order = append(order[:i], order[i+1:]...)
+                        break
+                    }
+                }
+            }
+            // Keep waiting for a quiet period, but never hold events back indefinitely.
+            if time.Since(oldest) < 10*w.opts.Debounce {
+                timer.Reset(w.opts.Debounce)
+            }
+        case <-timer.C:
+            if !flush() {
+                return
+            }
+        case <-ctx.Done():
+            return
+        }
+    }
+}
+
+// mergeWatchEvents combines two events for the same path. keep is false when the
+// events cancel out, such as a file created and deleted within the debounce window.
+func mergeWatchEvents(prev, next WatchEvent) (WatchEvent, bool) {
+    switch {
+    case prev.Op == WatchCreated && next.Op == WatchDeleted:
+        return next, false
+    case prev.Op == WatchCreated && next.Op == WatchModified:
+        prev.Time = next.Time
+        return prev, true
+    case prev.Op == WatchRenamed && next.Op == WatchModified:
+        prev.Time = next.Time
+        return prev, true
+    case prev.Op == WatchDeleted && next.Op == WatchCreated:
+        next.Op = WatchModified
+        return next, true
+    }
+    return next, true
+}
+
+// matchAnyGlob reports whether path, by base name or by slash-separated path relative
+// to root, matches any of the patterns.
+func matchAnyGlob(root, path string, patterns []string) bool {
+    if len(patterns) == 0 {
+        return false
+    }
+    base := filepath.Base(path)
+    rel, err := filepath.Rel(root, path)
+    if err != nil {
+        rel = path
+    }
+    rel = filepath.ToSlash(rel)
+
+    for _, pattern := range patterns {
+        if ok, _ := filepath.Match(pattern, base); ok {
+            return true
+        }
+        if ok, _ := filepath.Match(pattern, rel); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// treeEntry is what the polling backend remembers about each path.
+type treeEntry struct {
+    size     int64
+    modTime  time.Time
+    isDir    bool
+    identity uint64
+}
+
+// scanTree walks root and records every entry, skipping unreadable and excluded directories.
+func scanTree(root string, skipDir func(string) bool) (map[string]treeEntry, error) {
+    entries := make(map[string]treeEntry)
+    err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            if path == root {
+                return err
+            }
+            return nil
+        }
+        if path == root {
+            return nil
+        }
+        if d.IsDir() && skipDir(path) {
+            return filepath.SkipDir
+        }
+        info, err := d.Info()
+        if err != nil {
+            return nil
+        }
+        entries[path] = treeEntry{
+            size:     info.Size(),
+            modTime:  info.ModTime(),
+            isDir:    d.IsDir(),
+            identity: fileIdentity(info),
+        }
+        return nil
+    })
+    return entries, err
+}
+
+// watchPolling diffs successive tree snapshots and sends the differences to raw.
+// A deletion and creation sharing a file identity are reported as a rename.
+func watchPolling(ctx context.Context, root string, prev map[string]treeEntry, interval time.Duration,
+    skipDir func(string) bool, raw chan<- WatchEvent, fail func(error)) {
+    defer close(raw)
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+
+        next, err := scanTree(root, skipDir)
+        if err != nil {
+            fail(err)
+            return
+        }
+
+        now := time.Now()
+        var events []WatchEvent
+        removed := make(map[uint64]string)
+        for path, old := range prev {
+            if _, ok := next[path]; !ok && old.identity != 0 {
+                removed[old.identity] = path
+            }
+        }
+        renamedFrom := make(map[string]bool)
+        for path, cur := range next {
+            old, ok := prev[path]
+            switch {
+            case !ok:
+                if from, renamed := removed[cur.identity]; renamed && cur.identity != 0 {
+                    delete(removed, cur.identity)
+                    renamedFrom[from] = true
+                    events = append(events, WatchEvent{Op: WatchRenamed, Path: path, OldPath: from, IsDir: cur.isDir, Time: now})
+                    continue
+                }
+                events = append(events, WatchEvent{Op: WatchCreated, Path: path, IsDir: cur.isDir, Time: now})
+            case !cur.isDir && (old.size != cur.size || !old.modTime.Equal(cur.modTime)):
+                events = append(events, WatchEvent{Op: WatchModified, Path: path, Time: now})
+            }
+        }
+        for path, old := range prev {
+            if _, ok := next[path]; !ok && !renamedFrom[path] {
+                events = append(events, WatchEvent{Op: WatchDeleted, Path: path, IsDir: old.isDir, Time: now})
+            }
+        }
+
+        for _, ev := range events {
+            select {
+            case raw <- ev:
+            case <-ctx.Done():
+                return
+            }
+        }
+        prev = next
+    }
+}
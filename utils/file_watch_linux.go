@@ -0,0 +1,227 @@
+//go:build linux
+
+package utils
+
+import (
+    "context"
+    "errors"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+    "unsafe"
+)
+
+// inotifyMask is the set of inotify events watched on every directory.
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_DELETE |
+    syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotifyWatcher maps inotify watch descriptors back to directory paths.
+type inotifyWatcher struct {
+    fd      int
+    file    *os.File
+    paths   map[int32]string
+    skipDir func(string) bool
+    raw     chan<- WatchEvent
+}
+
+// movedFrom is the first half of a rename, waiting for its IN_MOVED_TO partner.
+type movedFrom struct {
+    path  string
+    isDir bool
+}
+
+// watchNative starts an inotify watch on every directory under root. It returns an
+// error, so the caller can fall back to polling, if inotify cannot be initialised.
+func watchNative(ctx context.Context, root string, skipDir func(string) bool, raw chan<- WatchEvent, fail func(error)) error {
+    fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+    if err != nil {
+        return err
+    }
+
+    // A non-blocking descriptor lets the runtime poller interrupt Read when the file is closed.
+    in := &inotifyWatcher{
+        fd:      fd,
+        file:    os.NewFile(uintptr(fd), "inotify"),
+        paths:   make(map[int32]string),
+        skipDir: skipDir,
+        raw:     raw,
+    }
+    if err := in.addTree(ctx, root, false); err != nil {
+        in.file.Close()
+        return err
+    }
+
+    go in.run(ctx, fail)
+    return nil
+}
+
+// addTree watches dir and every directory below it. With emit set, entries found inside
+// are reported as created, since they may have appeared before the watch was in place.
+func (in *inotifyWatcher) addTree(ctx context.Context, dir string, emit bool) error {
+    return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            if path == dir && !emit {
+                return err
+            }
+            return nil
+        }
+        if path != dir && emit {
+            in.send(ctx, WatchEvent{Op: WatchCreated, Path: path, IsDir: d.IsDir(), Time: time.Now()})
+        }
+        if !d.IsDir() {
+            return nil
+        }
+        if path != dir && in.skipDir(path) {
+            return filepath.SkipDir
+        }
+
+        wd, err := syscall.InotifyAddWatch(in.fd, path, inotifyMask)
+        if err != nil {
+            // Running out of watches means inotify cannot cover the tree; anything else
+            // (permissions, a directory vanishing mid-walk) only affects this directory.
+            if errors.Is(err, syscall.ENOSPC) && !emit {
+                return err
+            }
+            return nil
+        }
+        in.paths[int32(wd)] = path
+        return nil
+    })
+}
+
+// run reads inotify events until ctx is done or reading fails.
+func (in *inotifyWatcher) run(ctx context.Context, fail func(error)) {
+    defer close(in.raw)
+
+    stop := make(chan struct{})
+    defer close(stop)
+    go func() {
+        select {
+        case <-ctx.Done():
+        case <-stop:
+        }
+        in.file.Close()
+    }()
+
+    buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+    for {
+        n, err := in.file.Read(buf)
+        if err != nil {
+            if ctx.Err() == nil && !errors.Is(err, os.ErrClosed) {
+                fail(err)
+            }
+            return
+        }
+        if !in.handle(ctx, buf[:n]) {
+            fail(ErrWatchOverflow)
+            return
+        }
+    }
+}
+
+// handle translates one read's worth of inotify events into WatchEvents.
+// It returns false if the kernel queue overflowed and events were dropped.
+func (in *inotifyWatcher) handle(ctx context.Context, buf []byte) bool {
+    moves := make(map[uint32]movedFrom)
+    var order []uint32
+
+    for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+        raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+        nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+        offset += syscall.SizeofInotifyEvent + int(raw.Len)
+
+        if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+            return false
+        }
+        if raw.Mask&syscall.IN_IGNORED != 0 {
+            delete(in.paths, raw.Wd)
+            continue
+        }
+        dir, ok := in.paths[raw.Wd]
+        if !ok {
+            continue
+        }
+        name := strings.TrimRight(string(nameBytes), "\x00")
+        if name == "" {
+            continue
+        }
+
+        path := filepath.Join(dir, name)
+        isDir := raw.Mask&syscall.IN_ISDIR != 0
+        now := time.Now()
+
+        switch {
+        case raw.Mask&syscall.IN_CREATE != 0:
+            in.send(ctx, WatchEvent{Op: WatchCreated, Path: path, IsDir: isDir, Time: now})
+            if isDir && !in.skipDir(path) {
+                _ = in.addTree(ctx, path, true)
+            }
+        case raw.Mask&syscall.IN_MODIFY != 0:
+            in.send(ctx, WatchEvent{Op: WatchModified, Path: path, Time: now})
+        case raw.Mask&syscall.IN_DELETE != 0:
+            in.send(ctx, WatchEvent{Op: WatchDeleted, Path: path, IsDir: isDir, Time: now})
+        case raw.Mask&syscall.IN_MOVED_FROM != 0:
+            moves[raw.Cookie] = movedFrom{path: path, isDir: isDir}
+            order = append(order, raw.Cookie)
+        case raw.Mask&syscall.IN_MOVED_TO != 0:
+            from, paired := moves[raw.Cookie]
+            if paired {
+                delete(moves, raw.Cookie)
+                in.send(ctx, WatchEvent{Op: WatchRenamed, Path: path, OldPath: from.path, IsDir: isDir, Time: now})
+                if isDir {
+                    in.renameWatches(from.path, path)
+                }
+                continue
+            }
+            in.send(ctx, WatchEvent{Op: WatchCreated, Path: path, IsDir: isDir, Time: now})
+            if isDir && !in.skipDir(path) {
+                _ = in.addTree(ctx, path, true)
+            }
+        }
+    }
+
+    // Anything moved out of the tree without a partner is gone as far as we are concerned.
+    for _, cookie := range order {
+        from, ok := moves[cookie]
+        if !ok {
+            continue
+        }
+        in.send(ctx, WatchEvent{Op: WatchDeleted, Path: from.path, IsDir: from.isDir, Time: time.Now()})
+        if from.isDir {
+            in.removeWatches(from.path)
+        }
+    }
+    return true
+}
+
+// renameWatches updates watched paths after a directory moved within the tree.
+func (in *inotifyWatcher) renameWatches(from, to string) {
+    for wd, path := range in.paths {
+        if path == from {
+            in.paths[wd] = to
+        } else if strings.HasPrefix(path, from+string(filepath.Separator)) {
+            in.paths[wd] = to + strings.TrimPrefix(path, from)
+        }
+    }
+}
+
+// removeWatches drops the watches on a directory that left the tree, and everything below it.
+func (in *inotifyWatcher) removeWatches(dir string) {
+    for wd, path := range in.paths {
+        if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+            _, _ = syscall.InotifyRmWatch(in.fd, uint32(wd))
+            delete(in.paths, wd)
+        }
+    }
+}
+
+// send forwards ev to the coalescer unless ctx is done.
+func (in *inotifyWatcher) send(ctx context.Context, ev WatchEvent) {
+    select {
+    case in.raw <- ev:
+    case <-ctx.Done():
+    }
+}
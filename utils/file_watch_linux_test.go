@@ -0,0 +1,24 @@
+//go:build linux
+
+package utils
+
+import (
+    "context"
+    "syscall"
+    "testing"
+    "unsafe"
+)
+
+// TestInotifyOverflowReported checks that a queue overflow stops event handling
+// instead of being silently skipped.
+func TestInotifyOverflowReported(t *testing.T) {
+    in := &inotifyWatcher{paths: make(map[int32]string)}
+    buf := make([]byte, syscall.SizeofInotifyEvent)
+    ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[0]))
+    ev.Wd = -1
+    ev.Mask = syscall.IN_Q_OVERFLOW
+
+    if in.handle(context.Background(), buf) {
+        t.Fatal("handle accepted an overflow event")
+    }
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package utils
+
+import (
+    "context"
+)
+
+// watchNative is unavailable outside Linux, so DirWatcher always polls.
+func watchNative(ctx context.Context, root string, skipDir func(string) bool, raw chan<- WatchEvent, fail func(error)) error {
+    return errNativeWatchUnavailable
+}
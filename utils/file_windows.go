@@ -0,0 +1,57 @@
+//go:build windows
+
+package utils
+
+import (
+    "errors"
+    "os"
+    "syscall"
+)
+
+// errorNotSameDevice is ERROR_NOT_SAME_DEVICE, returned by MoveFileEx across volumes.
+const errorNotSameDevice syscall.Errno = 17
+
+// copyOwnership is a no-op on Windows, where files do not carry a numeric owner.
+func copyOwnership(f *os.File, info os.FileInfo) error {
+    return nil
+}
+
+// isCrossDevice reports whether err is a rename failing because src and dst are on
+// different volumes.
+func isCrossDevice(err error) bool {
+    return errors.Is(err, errorNotSameDevice)
+}
+
+// syncDir is a no-op on Windows, which cannot fsync directories.
+func syncDir(dir string) error {
+    return nil
+}
+
+// fileIdentity returns 0 on Windows, where FileInfo carries no stable file ID;
+// checkpoints then fall back to comparing offsets against the file size.
+func fileIdentity(info os.FileInfo) uint64 {
+    return 0
+}
+
+// tryLockFile is not supported on Windows.
+func tryLockFile(f *os.File, exclusive bool) error {
+    return errors.New("file locking is not supported on windows")
+}
+
+// unlockFile is not supported on Windows.
+func unlockFile(f *os.File) error {
+    return errors.New("file locking is not supported on windows")
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+    if pid <= 0 {
+        return false
+    }
+    p, err := os.FindProcess(pid)
+    if err != nil {
+        return false
+    }
+    p.Release()
+    return true
+}
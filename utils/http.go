@@ -2,30 +2,186 @@ package utils
 
 import (
     "bytes"
+    "context"
+    "crypto/tls"
+    "crypto/x509"
     "errors"
     "fmt"
     "io"
+    "net"
     "net/http"
+    "net/url"
+    "os"
+    "strings"
     "time"
 )
 
+// DefaultHTTPTimeout is the overall request timeout used when RESTClientOptions leaves it unset.
+const DefaultHTTPTimeout = 10 * time.Second
+
 // HTTPClient provides a shared client with timeout settings.
+// It is used by the package-level HTTP helpers through the default RESTClient,
+// wrapped in any interceptors added with UseHTTPInterceptors.
 var HTTPClient = &http.Client{
-    Timeout: 10 * time.Second,
+    Timeout: DefaultHTTPTimeout,
 }
 
-// HTTPRequest performs a generic HTTP request with method, headers, and optional body.
-func HTTPRequest(method, url string, headers map[string]string, body []byte) ([]byte, int, error) {
-    req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+// defaultRESTClient backs the package-level helpers and always uses HTTPClient.
+var defaultRESTClient = &RESTClient{}
+
+// RESTClientOptions configures a RESTClient.
+//
+// Relative request URLs are appended to BaseURL. Headers are sent with every request and
+// can be overridden per call. Timeout bounds a whole request, including retries; the other
+// timeouts bound its phases. Proxy is a proxy URL; when empty the environment variables
+// (HTTP_PROXY, HTTPS_PROXY, NO_PROXY) are used. CAFile adds a PEM bundle to the system
+// roots, ClientCertFile and ClientKeyFile enable mTLS, and TLSMinVersion defaults to TLS 1.2.
+// Retry and Breaker enable retries and a circuit breaker as in NewRetryTransport, and Auth
+// authenticates every request as in NewAuthTransport. Interceptors wrap all of these in
+// order, so each call passes through them once however many attempts it takes.
+type RESTClientOptions struct {
+    BaseURL               string
+    Headers               map[string]string
+    Timeout               time.Duration
+    DialTimeout           time.Duration
+    TLSHandshakeTimeout   time.Duration
+    ResponseHeaderTimeout time.Duration
+    Proxy                 string
+    CAFile                string
+    ClientCertFile        string
+    ClientKeyFile         string
+    TLSMinVersion         uint16
+    Retry                 *RetryPolicy
+    Breaker               *CircuitBreaker
+    Auth                  Authenticator
+    Interceptors          []Interceptor
+}
+
+// RESTClient is an HTTP client with its own transport, base URL and default headers.
+// It is safe for concurrent use.
+type RESTClient struct {
+    client  *http.Client
+    baseURL string
+    headers map[string]string
+}
+
+// NewRESTClient builds a RESTClient from opts.
+func NewRESTClient(opts RESTClientOptions) (*RESTClient, error) {
+    if opts.Timeout <= 0 {
+        opts.Timeout = DefaultHTTPTimeout
+    }
+    if opts.TLSMinVersion == 0 {
+        opts.TLSMinVersion = tls.VersionTLS12
+    }
+
+    tlsConfig, err := newTLSConfig(opts)
     if err != nil {
-        return nil, 0, err
+        return nil, err
     }
 
-    for key, val := range headers {
-        req.Header.Set(key, val)
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.TLSClientConfig = tlsConfig
+    if opts.DialTimeout > 0 {
+        transport.DialContext = (&net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+    }
+    if opts.TLSHandshakeTimeout > 0 {
+        transport.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+    }
+    if opts.ResponseHeaderTimeout > 0 {
+        transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+    }
+    if opts.Proxy != "" {
+        proxy, err := url.Parse(opts.Proxy)
+        if err != nil {
+            return nil, fmt.Errorf("invalid proxy URL: %w", err)
+        }
+        transport.Proxy = http.ProxyURL(proxy)
+    }
+
+    var rt http.RoundTripper = transport
+    if opts.Retry != nil {
+        rt = NewRetryTransport(rt, *opts.Retry, opts.Breaker)
+    } else if opts.Breaker != nil {
+        rt = NewRetryTransport(rt, RetryPolicy{MaxAttempts: 1}, opts.Breaker)
+    }
+    if opts.Auth != nil {
+        rt = NewAuthTransport(rt, opts.Auth)
+    }
+    rt = ChainInterceptors(rt, opts.Interceptors...)
+
+    headers := make(map[string]string, len(opts.Headers))
+    for key, val := range opts.Headers {
+        headers[key] = val
+    }
+
+    return &RESTClient{
+        client:  &http.Client{Transport: rt, Timeout: opts.Timeout},
+        baseURL: strings.TrimRight(opts.BaseURL, "/"),
+        headers: headers,
+    }, nil
+}
+
+// newTLSConfig builds the TLS settings for a RESTClient.
+func newTLSConfig(opts RESTClientOptions) (*tls.Config, error) {
+    config := &tls.Config{MinVersion: opts.TLSMinVersion}
+
+    if opts.CAFile != "" {
+        pem, err := os.ReadFile(opts.CAFile)
+        if err != nil {
+            return nil, err
+        }
+        pool, err := x509.SystemCertPool()
+        if err != nil {
+            pool = x509.NewCertPool()
+        }
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+        }
+        config.RootCAs = pool
+    }
+
+    if opts.ClientCertFile != "" || opts.ClientKeyFile != "" {
+        if opts.ClientCertFile == "" || opts.ClientKeyFile == "" {
+            return nil, errors.New("client certificate and key must be set together")
+        }
+        cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+        if err != nil {
+            return nil, err
+        }
+        config.Certificates = []tls.Certificate{cert}
+    }
+    return config, nil
+}
+
+// httpClient returns the underlying client; the default instance follows HTTPClient
+// and the interceptors added with UseHTTPInterceptors.
+func (c *RESTClient) httpClient() *http.Client {
+    if c.client == nil {
+        return defaultHTTPClient()
+    }
+    return c.client
+}
+
+// resolve joins a relative URL onto the base URL. Absolute URLs, those with a scheme,
+// are used as is; a URL appearing only in the query does not make the URL absolute.
+func (c *RESTClient) resolve(rawURL string) string {
+    if c.baseURL == "" {
+        return rawURL
+    }
+    if u, err := url.Parse(rawURL); err == nil && u.IsAbs() {
+        return rawURL
     }
+    return c.baseURL + "/" + strings.TrimLeft(rawURL, "/")
+}
 
-    resp, err := HTTPClient.Do(req)
+// Request performs an HTTP request with method, headers, and optional body.
+func (c *RESTClient) Request(method, url string, headers map[string]string, body []byte) ([]byte, int, error) {
+    return c.RequestContext(context.Background(), method, url, headers, body)
+}
+
+// RequestContext performs an HTTP request that is cancelled when ctx is done.
+func (c *RESTClient) RequestContext(ctx context.Context, method, url string, headers map[string]string, body []byte) ([]byte, int, error) {
+    resp, err := c.send(ctx, c.httpClient(), method, url, headers, bytes.NewReader(body))
     if err != nil {
         return nil, 0, err
     }
@@ -37,33 +193,85 @@ func HTTPRequest(method, url string, headers map[string]string, body []byte) ([]
     }
 
     if resp.StatusCode >= 400 {
-        return responseBody, resp.StatusCode, errors.New(fmt.Sprintf("HTTP error %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode)))
+        return responseBody, resp.StatusCode, newHTTPError(resp, responseBody)
     }
 
     return responseBody, resp.StatusCode, nil
 }
 
+// send builds a request with the default and per-call headers and sends it with client.
+func (c *RESTClient) send(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body io.Reader) (*http.Response, error) {
+    req, err := http.NewRequestWithContext(ctx, method, c.resolve(url), body)
+    if err != nil {
+        return nil, err
+    }
+
+    for key, val := range c.headers {
+        req.Header.Set(key, val)
+    }
+    for key, val := range headers {
+        req.Header.Set(key, val)
+    }
+
+    return client.Do(req)
+}
+
+// Get performs an HTTP GET request.
+func (c *RESTClient) Get(url string, headers map[string]string) ([]byte, int, error) {
+    return c.Request(http.MethodGet, url, headers, nil)
+}
+
+// Post performs an HTTP POST request with a JSON body.
+func (c *RESTClient) Post(url string, headers map[string]string, body []byte) ([]byte, int, error) {
+    return c.Request(http.MethodPost, url, headers, body)
+}
+
+// Put performs an HTTP PUT request with a JSON body.
+func (c *RESTClient) Put(url string, headers map[string]string, body []byte) ([]byte, int, error) {
+    return c.Request(http.MethodPut, url, headers, body)
+}
+
+// Patch performs an HTTP PATCH request with a JSON body.
+func (c *RESTClient) Patch(url string, headers map[string]string, body []byte) ([]byte, int, error) {
+    return c.Request(http.MethodPatch, url, headers, body)
+}
+
+// Delete performs an HTTP DELETE request.
+func (c *RESTClient) Delete(url string, headers map[string]string) ([]byte, int, error) {
+    return c.Request(http.MethodDelete, url, headers, nil)
+}
+
+// HTTPRequest performs a generic HTTP request with method, headers, and optional body.
+func HTTPRequest(method, url string, headers map[string]string, body []byte) ([]byte, int, error) {
+    return defaultRESTClient.Request(method, url, headers, body)
+}
+
+// HTTPRequestContext performs an HTTP request with the default client that is cancelled when ctx is done.
+func HTTPRequestContext(ctx context.Context, method, url string, headers map[string]string, body []byte) ([]byte, int, error) {
+    return defaultRESTClient.RequestContext(ctx, method, url, headers, body)
+}
+
 // HTTPGet performs an HTTP GET request.
 func HTTPGet(url string, headers map[string]string) ([]byte, int, error) {
-    return HTTPRequest(http.MethodGet, url, headers, nil)
+    return defaultRESTClient.Get(url, headers)
 }
 
 // HTTPPost performs an HTTP POST request with a JSON body.
 func HTTPPost(url string, headers map[string]string, body []byte) ([]byte, int, error) {
-    return HTTPRequest(http.MethodPost, url, headers, body)
+    return defaultRESTClient.Post(url, headers, body)
 }
 
 // HTTPPut performs an HTTP PUT request with a JSON body.
 func HTTPPut(url string, headers map[string]string, body []byte) ([]byte, int, error) {
-    return HTTPRequest(http.MethodPut, url, headers, body)
+    return defaultRESTClient.Put(url, headers, body)
 }
 
 // HTTPPatch performs an HTTP PATCH request with a JSON body.
 func HTTPPatch(url string, headers map[string]string, body []byte) ([]byte, int, error) {
-    return HTTPRequest(http.MethodPatch, url, headers, body)
+    return defaultRESTClient.Patch(url, headers, body)
 }
 
 // HTTPDelete performs an HTTP DELETE request.
 func HTTPDelete(url string, headers map[string]string) ([]byte, int, error) {
-    return HTTPRequest(http.MethodDelete, url, headers, nil)
+    return defaultRESTClient.Delete(url, headers)
 }
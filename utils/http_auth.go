@@ -0,0 +1,336 @@
+package utils
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultTokenRefreshSkew is how long before expiry an OAuth2 token is refreshed when
+// OAuth2Config leaves it unset.
+const DefaultTokenRefreshSkew = 30 * time.Second
+
+// DefaultAPIKeyHeader is the header used by APIKeyAuth when none is given.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// Authenticator adds credentials to an outgoing request.
+type Authenticator interface {
+    Authenticate(req *http.Request) error
+}
+
+// tokenInvalidator is implemented by authenticators whose credentials can go stale.
+// invalidate discards the token that produced the given Authorization header.
+type tokenInvalidator interface {
+    invalidate(authorization string)
+}
+
+// bearerAuth sends a fixed bearer token.
+type bearerAuth struct {
+    token string
+}
+
+// BearerAuth returns an Authenticator that sends "Authorization: Bearer <token>".
+func BearerAuth(token string) Authenticator {
+    return &bearerAuth{token: token}
+}
+
+// Authenticate implements Authenticator.
+func (a *bearerAuth) Authenticate(req *http.Request) error {
+    req.Header.Set("Authorization", "Bearer "+a.token)
+    return nil
+}
+
+// basicAuth sends HTTP basic credentials.
+type basicAuth struct {
+    username string
+    password string
+}
+
+// BasicAuth returns an Authenticator that sends HTTP basic credentials.
+func BasicAuth(username, password string) Authenticator {
+    return &basicAuth{username: username, password: password}
+}
+
+// Authenticate implements Authenticator.
+func (a *basicAuth) Authenticate(req *http.Request) error {
+    req.SetBasicAuth(a.username, a.password)
+    return nil
+}
+
+// apiKeyAuth sends an API key in a header.
+type apiKeyAuth struct {
+    header string
+    key    string
+}
+
+// APIKeyAuth returns an Authenticator that sends key in the given header,
+// or DefaultAPIKeyHeader if header is empty.
+func APIKeyAuth(header, key string) Authenticator {
+    if header == "" {
+        header = DefaultAPIKeyHeader
+    }
+    return &apiKeyAuth{header: header, key: key}
+}
+
+// Authenticate implements Authenticator.
+func (a *apiKeyAuth) Authenticate(req *http.Request) error {
+    req.Header.Set(a.header, a.key)
+    return nil
+}
+
+// OAuth2Config configures the OAuth2 client-credentials flow.
+//
+// The client authenticates to TokenURL with HTTP basic auth, or with client_id and
+// client_secret form fields when CredentialsInBody is set. EndpointParams adds extra form
+// fields such as "audience". Tokens are refreshed RefreshSkew before they expire, but no
+// earlier than halfway through their lifetime.
+// HTTPClient is used for the token endpoint and defaults to a client with DefaultHTTPTimeout.
+type OAuth2Config struct {
+    TokenURL          string
+    ClientID          string
+    ClientSecret      string
+    Scopes            []string
+    EndpointParams    map[string]string
+    CredentialsInBody bool
+    RefreshSkew       time.Duration
+    HTTPClient        *http.Client
+}
+
+// OAuth2ClientCredentials is an Authenticator that obtains, caches and refreshes access
+// tokens with the client-credentials grant. It is safe for concurrent use.
+type OAuth2ClientCredentials struct {
+    config OAuth2Config
+
+    mu        sync.Mutex
+    token     string
+    kind      string
+    refreshAt time.Time
+    pending   *tokenFetch
+}
+
+// tokenFetch is a token request in flight; concurrent callers wait for it instead of
+// sending their own.
+type tokenFetch struct {
+    done      chan struct{}
+    token     string
+    kind      string
+    err       error
+    abandoned bool
+}
+
+// NewOAuth2ClientCredentials creates a client-credentials Authenticator.
+func NewOAuth2ClientCredentials(config OAuth2Config) *OAuth2ClientCredentials {
+    if config.RefreshSkew <= 0 {
+        config.RefreshSkew = DefaultTokenRefreshSkew
+    }
+    if config.HTTPClient == nil {
+        config.HTTPClient = &http.Client{Timeout: DefaultHTTPTimeout}
+    }
+    return &OAuth2ClientCredentials{config: config}
+}
+
+// Authenticate implements Authenticator, fetching a token first if none is cached or it
+// is about to expire.
+func (o *OAuth2ClientCredentials) Authenticate(req *http.Request) error {
+    token, kind, err := o.current(req.Context())
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Authorization", kind+" "+token)
+    return nil
+}
+
+// Token returns a valid access token, fetching a new one if needed.
+func (o *OAuth2ClientCredentials) Token(ctx context.Context) (string, error) {
+    token, _, err := o.current(ctx)
+    return token, err
+}
+
+// current returns the cached token and its type, refreshing it when due. Only one
+// refresh runs at a time and the lock is not held while it does.
+func (o *OAuth2ClientCredentials) current(ctx context.Context) (string, string, error) {
+    for {
+        o.mu.Lock()
+        if o.token != "" && (o.refreshAt.IsZero() || time.Now().Before(o.refreshAt)) {
+            token, kind := o.token, o.kind
+            o.mu.Unlock()
+            return token, kind, nil
+        }
+        if f := o.pending; f != nil {
+            o.mu.Unlock()
+            select {
+            case <-f.done:
+            case <-ctx.Done():
+                return "", "", ctx.Err()
+            }
+            if f.abandoned {
+                // The caller that started the fetch gave up; try again with this context.
+                continue
+            }
+            return f.token, f.kind, f.err
+        }
+        f := &tokenFetch{done: make(chan struct{})}
+        o.pending = f
+        o.mu.Unlock()
+
+        token, kind, lifetime, err := o.fetch(ctx)
+
+        o.mu.Lock()
+        o.pending = nil
+        if err == nil {
+            o.token, o.kind = token, kind
+            o.refreshAt = time.Time{}
+            if lifetime > 0 {
+                // Never refresh earlier than halfway through, or short-lived tokens would
+                // be fetched again on every request.
+                skew := o.config.RefreshSkew
+                if skew > lifetime/2 {
+                    skew = lifetime / 2
+                }
+                o.refreshAt = time.Now().Add(lifetime - skew)
+            }
+        }
+        o.mu.Unlock()
+
+        f.token, f.kind, f.err = token, kind, err
+        f.abandoned = err != nil && ctx.Err() != nil
+        close(f.done)
+        return token, kind, err
+    }
+}
+
+// invalidate drops the cached token if it is the one that produced authorization.
+func (o *OAuth2ClientCredentials) invalidate(authorization string) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    if authorization == o.kind+" "+o.token {
+        o.token = ""
+    }
+}
+
+// fetch requests a new token from the token endpoint and returns it with its type and
+// lifetime, which is zero if the server gave none.
+func (o *OAuth2ClientCredentials) fetch(ctx context.Context) (string, string, time.Duration, error) {
+    form := url.Values{"grant_type": {"client_credentials"}}
+    if len(o.config.Scopes) > 0 {
+        form.Set("scope", strings.Join(o.config.Scopes, " "))
+    }
+    for key, val := range o.config.EndpointParams {
+        form.Set(key, val)
+    }
+    if o.config.CredentialsInBody {
+        form.Set("client_id", o.config.ClientID)
+        form.Set("client_secret", o.config.ClientSecret)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.config.TokenURL, strings.NewReader(form.Encode()))
+    if err != nil {
+        return "", "", 0, err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Accept", "application/json")
+    if !o.config.CredentialsInBody {
+        req.SetBasicAuth(url.QueryEscape(o.config.ClientID), url.QueryEscape(o.config.ClientSecret))
+    }
+
+    resp, err := o.config.HTTPClient.Do(req)
+    if err != nil {
+        return "", "", 0, fmt.Errorf("oauth2 token request: %w", err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+    if err != nil {
+        return "", "", 0, fmt.Errorf("oauth2 token request: %w", err)
+    }
+    if resp.StatusCode >= 400 {
+        return "", "", 0, fmt.Errorf("oauth2 token request: %w", newHTTPError(resp, body))
+    }
+
+    var token struct {
+        AccessToken string `json:"access_token"`
+        TokenType   string `json:"token_type"`
+        ExpiresIn   int64  `json:"expires_in"`
+    }
+    if err := json.Unmarshal(body, &token); err != nil {
+        return "", "", 0, fmt.Errorf("oauth2 token response: %w", err)
+    }
+    if token.AccessToken == "" {
+        return "", "", 0, errors.New("oauth2 token response has no access_token")
+    }
+
+    kind := "Bearer"
+    if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+        kind = token.TokenType
+    }
+    var lifetime time.Duration
+    if token.ExpiresIn > 0 {
+        lifetime = time.Duration(token.ExpiresIn) * time.Second
+    }
+    return token.AccessToken, kind, lifetime, nil
+}
+
+// authTransport is an http.RoundTripper that authenticates every request.
+type authTransport struct {
+    base http.RoundTripper
+    auth Authenticator
+}
+
+// NewAuthTransport wraps base (http.DefaultTransport if nil) so that every request is
+// authenticated by auth. If the server answers 401 and auth can refresh its credentials,
+// as OAuth2ClientCredentials can, the token is discarded and the request retried once.
+// Install it as the Transport of HTTPClient to authenticate the package-level helpers.
+func NewAuthTransport(base http.RoundTripper, auth Authenticator) http.RoundTripper {
+    if base == nil {
+        base = http.DefaultTransport
+    }
+    return &authTransport{base: base, auth: auth}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    authed := req.Clone(req.Context())
+    if err := t.auth.Authenticate(authed); err != nil {
+        if req.Body != nil {
+            req.Body.Close()
+        }
+        return nil, err
+    }
+
+    resp, err := t.base.RoundTrip(authed)
+    if err != nil || resp.StatusCode != http.StatusUnauthorized {
+        return resp, err
+    }
+    invalidator, ok := t.auth.(tokenInvalidator)
+    if !ok || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+        return resp, nil
+    }
+
+    io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+    resp.Body.Close()
+    invalidator.invalidate(authed.Header.Get("Authorization"))
+
+    retry := req.Clone(req.Context())
+    if req.GetBody != nil {
+        body, err := req.GetBody()
+        if err != nil {
+            return nil, err
+        }
+        retry.Body = body
+    }
+    if err := t.auth.Authenticate(retry); err != nil {
+        if retry.Body != nil {
+            retry.Body.Close()
+        }
+        return nil, err
+    }
+    return t.base.RoundTrip(retry)
+}
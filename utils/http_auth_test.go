@@ -0,0 +1,87 @@
+package utils
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "sync/atomic"
+    "testing"
+)
+
+// TestOAuth2RefreshOn401 checks that a 401 discards the cached token, fetches exactly one
+// new token and retries the request once with it.
+func TestOAuth2RefreshOn401(t *testing.T) {
+    var fetches int32
+    tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        n := atomic.AddInt32(&fetches, 1)
+        w.Header().Set("Content-Type", "application/json")
+        fmt.Fprintf(w, `{"access_token":"tok%d","token_type":"bearer","expires_in":3600}`, n)
+    }))
+    defer tokens.Close()
+
+    // The API has already revoked the first token.
+    var calls int32
+    api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&calls, 1)
+        if r.Header.Get("Authorization") != "Bearer tok2" {
+            w.WriteHeader(http.StatusUnauthorized)
+        }
+    }))
+    defer api.Close()
+
+    auth := NewOAuth2ClientCredentials(OAuth2Config{TokenURL: tokens.URL, ClientID: "id", ClientSecret: "secret"})
+    client := &http.Client{Transport: NewAuthTransport(nil, auth)}
+
+    resp, err := client.Get(api.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("got status %d, want 200", resp.StatusCode)
+    }
+    if f, c := atomic.LoadInt32(&fetches), atomic.LoadInt32(&calls); f != 2 || c != 2 {
+        t.Fatalf("got %d token fetches and %d API calls, want 2 and 2", f, c)
+    }
+
+    // The refreshed token is cached for later requests.
+    resp, err = client.Get(api.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if f := atomic.LoadInt32(&fetches); f != 2 {
+        t.Fatalf("got %d token fetches after a second request, want 2", f)
+    }
+}
+
+// TestOAuth2RefreshOn401Once checks that a token rejected again after the refresh is
+// not refreshed in a loop.
+func TestOAuth2RefreshOn401Once(t *testing.T) {
+    var fetches int32
+    tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&fetches, 1)
+        w.Write([]byte(`{"access_token":"tok","expires_in":3600}`))
+    }))
+    defer tokens.Close()
+
+    api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusUnauthorized)
+    }))
+    defer api.Close()
+
+    auth := NewOAuth2ClientCredentials(OAuth2Config{TokenURL: tokens.URL})
+    client := &http.Client{Transport: NewAuthTransport(nil, auth)}
+
+    resp, err := client.Get(api.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusUnauthorized {
+        t.Fatalf("got status %d, want 401", resp.StatusCode)
+    }
+    if f := atomic.LoadInt32(&fetches); f != 2 {
+        t.Fatalf("got %d token fetches, want 2", f)
+    }
+}
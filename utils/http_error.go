@@ -0,0 +1,217 @@
+package utils
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net"
+    "net/http"
+    "net/url"
+    "strings"
+    "syscall"
+    "time"
+)
+
+// MaxHTTPErrorBody is the number of response body bytes kept in an HTTPError.
+const MaxHTTPErrorBody = 64 << 10
+
+// redactedQueryParams are query parameters whose values are hidden in HTTPError.URL.
+var redactedQueryParams = []string{
+    "access_token", "api_key", "apikey", "client_secret", "code", "key",
+    "password", "secret", "sig", "signature", "token",
+}
+
+// ProblemDetails holds the RFC 7807 fields of an application/problem+json error body.
+// Members other than the standard five are collected in Extensions.
+type ProblemDetails struct {
+    Type       string                 `json:"type,omitempty"`
+    Title      string                 `json:"title,omitempty"`
+    Status     int                    `json:"status,omitempty"`
+    Detail     string                 `json:"detail,omitempty"`
+    Instance   string                 `json:"instance,omitempty"`
+    Extensions map[string]interface{} `json:"-"`
+}
+
+// HTTPError is returned by every HTTP helper for responses with status >= 400.
+// Body holds at most MaxHTTPErrorBody bytes, and URL has its password and sensitive
+// query parameters redacted. Problem is set when the server replied with
+// application/problem+json.
+type HTTPError struct {
+    Method     string
+    URL        string
+    StatusCode int
+    Status     string
+    Header     http.Header
+    Body       []byte
+    Problem    *ProblemDetails
+}
+
+// Error implements the error interface.
+func (e *HTTPError) Error() string {
+    msg := fmt.Sprintf("HTTP error %d: %s", e.StatusCode, http.StatusText(e.StatusCode))
+    if e.Method != "" || e.URL != "" {
+        msg = e.Method + " " + e.URL + ": " + msg
+    }
+    if e.Problem != nil {
+        if e.Problem.Detail != "" {
+            return msg + ": " + e.Problem.Detail
+        }
+        if e.Problem.Title != "" {
+            return msg + ": " + e.Problem.Title
+        }
+    }
+    return msg
+}
+
+// RetryAfter returns the delay requested by the response's Retry-After header, if any.
+func (e *HTTPError) RetryAfter() (time.Duration, bool) {
+    return parseRetryAfter(e.Header.Get("Retry-After"))
+}
+
+// IsNotFound reports whether err is an HTTPError with status 404.
+func IsNotFound(err error) bool {
+    return hasHTTPStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err is an HTTPError with status 401.
+func IsUnauthorized(err error) bool {
+    return hasHTTPStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden reports whether err is an HTTPError with status 403.
+func IsForbidden(err error) bool {
+    return hasHTTPStatus(err, http.StatusForbidden)
+}
+
+// IsRetryable reports whether the request that produced err may succeed if repeated:
+// a retryable HTTP status (408, 429, 500, 502, 503, 504) or a transient network failure
+// such as a refused or reset connection or a timeout. Cancellation, an expired request
+// context, an open circuit breaker, invalid URLs and certificate errors are not retryable.
+func IsRetryable(err error) bool {
+    if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+        return false
+    }
+    // Transport timeouts also match context.DeadlineExceeded, so only the bare context
+    // error at the end of the chain means the caller's deadline has passed.
+    if rootCause(err) == context.DeadlineExceeded {
+        return false
+    }
+    var httpErr *HTTPError
+    if errors.As(err, &httpErr) {
+        for _, code := range defaultRetryStatuses {
+            if httpErr.StatusCode == code {
+                return true
+            }
+        }
+        return false
+    }
+    return isTransientNetError(err)
+}
+
+// isTransientNetError reports whether err is a connection-level failure that may clear up.
+// It looks through the *url.Error that http.Client wraps around every error.
+func isTransientNetError(err error) bool {
+    var certErr *tls.CertificateVerificationError
+    var unknownAuthority x509.UnknownAuthorityError
+    var hostnameErr x509.HostnameError
+    var invalidCert x509.CertificateInvalidError
+    if errors.As(err, &certErr) || errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) {
+        return false
+    }
+    var dnsErr *net.DNSError
+    if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+        return false
+    }
+
+    if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+        return true
+    }
+    var netErr net.Error
+    if errors.As(err, &netErr) && netErr.Timeout() {
+        return true
+    }
+    var opErr *net.OpError
+    return errors.As(err, &opErr)
+}
+
+// rootCause returns the innermost error in err's Unwrap chain.
+func rootCause(err error) error {
+    for {
+        next := errors.Unwrap(err)
+        if next == nil {
+            return err
+        }
+        err = next
+    }
+}
+
+// hasHTTPStatus reports whether err is an HTTPError with the given status.
+func hasHTTPStatus(err error, code int) bool {
+    var httpErr *HTTPError
+    return errors.As(err, &httpErr) && httpErr.StatusCode == code
+}
+
+// newHTTPError builds an HTTPError from a response and its already-read body.
+func newHTTPError(resp *http.Response, body []byte) *HTTPError {
+    e := &HTTPError{
+        StatusCode: resp.StatusCode,
+        Status:     resp.Status,
+        Header:     resp.Header,
+    }
+    if resp.Request != nil {
+        e.Method = resp.Request.Method
+        e.URL = redactURL(resp.Request.URL)
+    }
+    if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediaType == "application/problem+json" {
+        e.Problem = parseProblemDetails(body)
+    }
+    if len(body) > MaxHTTPErrorBody {
+        body = body[:MaxHTTPErrorBody]
+    }
+    e.Body = append([]byte(nil), body...)
+    return e
+}
+
+// redactURL hides the password and sensitive query parameter values in u.
+func redactURL(u *url.URL) string {
+    if u == nil {
+        return ""
+    }
+    clean := *u
+    if clean.RawQuery != "" {
+        query := clean.Query()
+        for name := range query {
+            for _, secret := range redactedQueryParams {
+                if strings.EqualFold(name, secret) {
+                    query.Set(name, "REDACTED")
+                }
+            }
+        }
+        clean.RawQuery = query.Encode()
+    }
+    return clean.Redacted()
+}
+
+// parseProblemDetails decodes an RFC 7807 body, or returns nil if it is not valid JSON.
+func parseProblemDetails(body []byte) *ProblemDetails {
+    var problem ProblemDetails
+    if err := json.Unmarshal(body, &problem); err != nil {
+        return nil
+    }
+    var members map[string]interface{}
+    if err := json.Unmarshal(body, &members); err != nil {
+        return nil
+    }
+    for _, key := range []string{"type", "title", "status", "detail", "instance"} {
+        delete(members, key)
+    }
+    if len(members) > 0 {
+        problem.Extensions = members
+    }
+    return &problem
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "reflect"
+)
+
+// DoJSON sends body as JSON, unless it is nil or a typed nil pointer, map or slice, and
+// decodes a successful JSON response into Resp.
+// Accept and Content-Type are set automatically; error responses are returned as *HTTPError.
+// A nil client uses the default client.
+func DoJSON[Resp any](ctx context.Context, c *RESTClient, method, url string, headers map[string]string, body interface{}) (Resp, error) {
+    var out Resp
+    if c == nil {
+        c = defaultRESTClient
+    }
+
+    merged := map[string]string{"Accept": "application/json"}
+    var reader io.Reader
+    if !isNilBody(body) {
+        data, err := json.Marshal(body)
+        if err != nil {
+            return out, err
+        }
+        reader = bytes.NewReader(data)
+        merged["Content-Type"] = "application/json"
+    }
+    for key, val := range headers {
+        merged[key] = val
+    }
+
+    resp, err := c.send(ctx, c.httpClient(), method, url, merged, reader)
+    if err != nil {
+        return out, err
+    }
+    defer resp.Body.Close()
+
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return out, err
+    }
+    if resp.StatusCode >= 400 {
+        return out, newHTTPError(resp, data)
+    }
+    if len(bytes.TrimSpace(data)) == 0 {
+        return out, nil
+    }
+    if err := json.Unmarshal(data, &out); err != nil {
+        return out, fmt.Errorf("decode %s response: %w", method, err)
+    }
+    return out, nil
+}
+
+// isNilBody reports whether body is nil, either untyped or as a nil pointer, map or slice,
+// any of which would otherwise be sent as a JSON null.
+func isNilBody(body interface{}) bool {
+    if body == nil {
+        return true
+    }
+    v := reflect.ValueOf(body)
+    switch v.Kind() {
+    case reflect.Ptr, reflect.Map, reflect.Slice:
+        return v.IsNil()
+    }
+    return false
+}
+
+// GetJSON performs a GET request and decodes the JSON response into T.
+func GetJSON[T any](ctx context.Context, url string, headers map[string]string) (T, error) {
+    return DoJSON[T](ctx, nil, http.MethodGet, url, headers, nil)
+}
+
+// PostJSON sends body as JSON in a POST request and decodes the JSON response into Resp.
+func PostJSON[Req, Resp any](ctx context.Context, url string, headers map[string]string, body Req) (Resp, error) {
+    return DoJSON[Resp](ctx, nil, http.MethodPost, url, headers, body)
+}
+
+// PutJSON sends body as JSON in a PUT request and decodes the JSON response into Resp.
+func PutJSON[Req, Resp any](ctx context.Context, url string, headers map[string]string, body Req) (Resp, error) {
+    return DoJSON[Resp](ctx, nil, http.MethodPut, url, headers, body)
+}
+
+// PatchJSON sends body as JSON in a PATCH request and decodes the JSON response into Resp.
+func PatchJSON[Req, Resp any](ctx context.Context, url string, headers map[string]string, body Req) (Resp, error) {
+    return DoJSON[Resp](ctx, nil, http.MethodPatch, url, headers, body)
+}
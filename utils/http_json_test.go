@@ -0,0 +1,47 @@
+package utils
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+// TestDoJSONTypedNilBody checks that a typed nil body sends no body and no Content-Type,
+// while a real value is encoded.
+func TestDoJSONTypedNilBody(t *testing.T) {
+    type payload struct {
+        Name string `json:"name"`
+    }
+
+    var gotBody, gotType string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        data, _ := io.ReadAll(r.Body)
+        gotBody, gotType = string(data), r.Header.Get("Content-Type")
+        w.Write([]byte(`{"name":"ok"}`))
+    }))
+    defer srv.Close()
+
+    bodies := map[string]interface{}{
+        "nil pointer": (*payload)(nil),
+        "nil map":     map[string]string(nil),
+        "nil slice":   []int(nil),
+    }
+    for name, body := range bodies {
+        out, err := DoJSON[payload](context.Background(), nil, http.MethodPost, srv.URL, nil, body)
+        if err != nil {
+            t.Fatalf("%s: %v", name, err)
+        }
+        if gotBody != "" || gotType != "" || out.Name != "ok" {
+            t.Fatalf("%s: sent %q with Content-Type %q", name, gotBody, gotType)
+        }
+    }
+
+    if _, err := DoJSON[payload](context.Background(), nil, http.MethodPost, srv.URL, nil, &payload{Name: "x"}); err != nil {
+        t.Fatal(err)
+    }
+    if gotBody != `{"name":"x"}` || gotType != "application/json" {
+        t.Fatalf("sent %q with Content-Type %q", gotBody, gotType)
+    }
+}
@@ -0,0 +1,305 @@
+package utils
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultRequestIDHeader is the header used by RequestIDInterceptor when none is given.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// defaultRedactedHeaders are headers whose values LoggingInterceptor never writes out.
+var defaultRedactedHeaders = []string{
+    "Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+    DefaultAPIKeyHeader, "X-Auth-Token",
+}
+
+// Interceptor wraps an http.RoundTripper with extra behaviour, such as logging or
+// adding headers. Interceptors must not modify the request they are given; clone it first.
+type Interceptor func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to the http.RoundTripper interface.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+    return f(req)
+}
+
+// ChainInterceptors wraps base (http.DefaultTransport if nil) with interceptors in order:
+// the first interceptor sees the request first and the response last.
+func ChainInterceptors(base http.RoundTripper, interceptors ...Interceptor) http.RoundTripper {
+    if base == nil {
+        base = http.DefaultTransport
+    }
+    rt := base
+    for i := len(interceptors) - 1; i >= 0; i-- {
+        rt = interceptors[i](rt)
+    }
+    return rt
+}
+
+var (
+    httpInterceptorsMu sync.RWMutex
+    httpInterceptors   []Interceptor
+)
+
+// UseHTTPInterceptors appends interceptors to the chain used by HTTPRequest and the other
+// package-level helpers. Like RESTClientOptions.Interceptors, they wrap HTTPClient's
+// transport from the outside, including any retry or auth transport installed on it, so
+// each call passes through them once. Interceptors added by later calls run after earlier ones.
+func UseHTTPInterceptors(interceptors ...Interceptor) {
+    httpInterceptorsMu.Lock()
+    defer httpInterceptorsMu.Unlock()
+    httpInterceptors = append(httpInterceptors, interceptors...)
+}
+
+// defaultHTTPClient returns HTTPClient with the registered interceptors around its transport.
+func defaultHTTPClient() *http.Client {
+    httpInterceptorsMu.RLock()
+    interceptors := httpInterceptors
+    httpInterceptorsMu.RUnlock()
+
+    if len(interceptors) == 0 {
+        return HTTPClient
+    }
+    client := *HTTPClient
+    client.Transport = ChainInterceptors(HTTPClient.Transport, interceptors...)
+    return &client
+}
+
+// HTTPLogOptions configures LoggingInterceptor.
+//
+// With Headers, request and response headers are logged. Values of Authorization, Cookie,
+// Set-Cookie, X-API-Key and similar headers are always replaced by REDACTED, as are those
+// of any header listed in RedactHeaders.
+type HTTPLogOptions struct {
+    Headers       bool
+    RedactHeaders []string
+}
+
+// LoggingInterceptor logs every exchange to logger as key=value pairs with the method,
+// redacted URL, status and latency. Failed requests are logged as errors and responses
+// with status >= 400 as warnings.
+func LoggingInterceptor(logger *Logger, opts HTTPLogOptions) Interceptor {
+    redact := make(map[string]bool, len(defaultRedactedHeaders)+len(opts.RedactHeaders))
+    for _, name := range defaultRedactedHeaders {
+        redact[http.CanonicalHeaderKey(name)] = true
+    }
+    for _, name := range opts.RedactHeaders {
+        redact[http.CanonicalHeaderKey(name)] = true
+    }
+
+    return func(next http.RoundTripper) http.RoundTripper {
+        return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+            start := time.Now()
+            resp, err := next.RoundTrip(req)
+            elapsed := time.Since(start)
+
+            line := fmt.Sprintf("http_request method=%s url=%s", req.Method, redactURL(req.URL))
+            if opts.Headers {
+                line += " request_headers=" + formatHeaders(req.Header, redact)
+            }
+            if err != nil {
+                logger.Errorf("%s duration=%s error=%q", line, elapsed, err)
+                return nil, err
+            }
+
+            line += fmt.Sprintf(" status=%d duration=%s", resp.StatusCode, elapsed)
+            if opts.Headers {
+                line += " response_headers=" + formatHeaders(resp.Header, redact)
+            }
+            if resp.StatusCode >= 400 {
+                logger.Warnf("%s", line)
+            } else {
+                logger.Infof("%s", line)
+            }
+            return resp, nil
+        })
+    }
+}
+
+// formatHeaders renders headers as {Name=value, ...} in name order, hiding redacted values.
+func formatHeaders(header http.Header, redact map[string]bool) string {
+    names := make([]string, 0, len(header))
+    for name := range header {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    parts := make([]string, 0, len(names))
+    for _, name := range names {
+        value := strings.Join(header[name], ",")
+        if redact[http.CanonicalHeaderKey(name)] {
+            value = "REDACTED"
+        }
+        parts = append(parts, name+"="+value)
+    }
+    return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// HTTPMetric describes one completed round trip. Duration runs until the response headers
+// arrive. StatusCode is 0 when Err is set.
+type HTTPMetric struct {
+    Method     string
+    Host       string
+    StatusCode int
+    Duration   time.Duration
+    Err        error
+}
+
+// MetricsInterceptor reports every round trip to observe, for example HTTPMetrics.Observe
+// or a function feeding an external metrics system.
+func MetricsInterceptor(observe func(HTTPMetric)) Interceptor {
+    return func(next http.RoundTripper) http.RoundTripper {
+        return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+            start := time.Now()
+            resp, err := next.RoundTrip(req)
+
+            metric := HTTPMetric{
+                Method:   req.Method,
+                Host:     req.URL.Host,
+                Duration: time.Since(start),
+                Err:      err,
+            }
+            if err == nil {
+                metric.StatusCode = resp.StatusCode
+            }
+            observe(metric)
+            return resp, err
+        })
+    }
+}
+
+// HTTPMetrics aggregates request counts, status codes and latency. The zero value is ready
+// to use and it is safe for concurrent use.
+type HTTPMetrics struct {
+    mu       sync.Mutex
+    requests int64
+    errors   int64
+    statuses map[int]int64
+    total    time.Duration
+    max      time.Duration
+}
+
+// HTTPMetricsSnapshot is a point-in-time copy of HTTPMetrics. Errors counts requests that
+// got no response; Statuses counts the others by status code.
+type HTTPMetricsSnapshot struct {
+    Requests     int64         `json:"requests"`
+    Errors       int64         `json:"errors"`
+    Statuses     map[int]int64 `json:"statuses"`
+    TotalLatency time.Duration `json:"total_latency"`
+    AvgLatency   time.Duration `json:"avg_latency"`
+    MaxLatency   time.Duration `json:"max_latency"`
+}
+
+// Observe records one round trip.
+func (m *HTTPMetrics) Observe(metric HTTPMetric) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.requests++
+    if metric.Err != nil {
+        m.errors++
+    } else {
+        if m.statuses == nil {
+            m.statuses = make(map[int]int64)
+        }
+        m.statuses[metric.StatusCode]++
+    }
+    m.total += metric.Duration
+    if metric.Duration > m.max {
+        m.max = metric.Duration
+    }
+}
+
+// Snapshot returns the metrics recorded so far.
+func (m *HTTPMetrics) Snapshot() HTTPMetricsSnapshot {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    snap := HTTPMetricsSnapshot{
+        Requests:     m.requests,
+        Errors:       m.errors,
+        Statuses:     make(map[int]int64, len(m.statuses)),
+        TotalLatency: m.total,
+        MaxLatency:   m.max,
+    }
+    for code, n := range m.statuses {
+        snap.Statuses[code] = n
+    }
+    if m.requests > 0 {
+        snap.AvgLatency = m.total / time.Duration(m.requests)
+    }
+    return snap
+}
+
+// requestIDKey is the context key for ContextWithRequestID.
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying id, which RequestIDInterceptor sends
+// on outgoing requests. Use it to propagate the ID of an incoming request.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+    return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored by ContextWithRequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+    id, _ := ctx.Value(requestIDKey{}).(string)
+    return id
+}
+
+// RequestIDInterceptor sets the given header (DefaultRequestIDHeader if empty) on requests
+// that lack it, using the ID from the request context or a new random one. Interceptors
+// run outside retries, so every attempt of a request carries the same ID.
+func RequestIDInterceptor(header string) Interceptor {
+    if header == "" {
+        header = DefaultRequestIDHeader
+    }
+    return func(next http.RoundTripper) http.RoundTripper {
+        return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+            if req.Header.Get(header) != "" {
+                return next.RoundTrip(req)
+            }
+            id := RequestIDFromContext(req.Context())
+            if id == "" {
+                id = newRandomID()
+            }
+            req = req.Clone(req.Context())
+            req.Header.Set(header, id)
+            return next.RoundTrip(req)
+        })
+    }
+}
+
+// UserAgentInterceptor sets the User-Agent header on requests that do not already have one.
+func UserAgentInterceptor(userAgent string) Interceptor {
+    return HeaderInterceptor(map[string]string{"User-Agent": userAgent})
+}
+
+// HeaderInterceptor sets each of headers on requests that do not already have it.
+func HeaderInterceptor(headers map[string]string) Interceptor {
+    return func(next http.RoundTripper) http.RoundTripper {
+        return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+            var clone *http.Request
+            for key, val := range headers {
+                if req.Header.Get(key) != "" {
+                    continue
+                }
+                if clone == nil {
+                    clone = req.Clone(req.Context())
+                }
+                clone.Header.Set(key, val)
+            }
+            if clone != nil {
+                req = clone
+            }
+            return next.RoundTrip(req)
+        })
+    }
+}
@@ -0,0 +1,346 @@
+package utils
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    mathrand "math/rand"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// Default retry and circuit breaker settings used when left unset.
+const (
+    DefaultRetryAttempts       = 3
+    DefaultRetryBackoffInitial = 200 * time.Millisecond
+    DefaultRetryBackoffMax     = 10 * time.Second
+    DefaultRetryJitter         = 0.5
+    DefaultMaxRetryAfter       = 1 * time.Minute
+    DefaultBreakerThreshold    = 5
+    DefaultBreakerCooldown     = 30 * time.Second
+)
+
+// IdempotencyKeyHeader is the header that marks a POST or PATCH request as safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ErrCircuitOpen is returned, wrapped with the host name, while a host's circuit is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// defaultRetryStatuses are the response codes retried when RetryPolicy.RetryStatuses is empty.
+var defaultRetryStatuses = []int{
+    http.StatusRequestTimeout,
+    http.StatusTooManyRequests,
+    http.StatusInternalServerError,
+    http.StatusBadGateway,
+    http.StatusServiceUnavailable,
+    http.StatusGatewayTimeout,
+}
+
+// RetryPolicy configures NewRetryTransport.
+//
+// MaxAttempts counts the first try. Transient network failures, as classified by
+// IsRetryable, and responses with one of RetryStatuses are retried. Delays grow
+// exponentially from BackoffInitial up to BackoffMax, with the Jitter fraction of each
+// delay randomised (negative disables jitter).
+// A Retry-After header replaces the computed delay; if it asks for longer than MaxRetryAfter
+// the response is returned as is. Only idempotent methods are retried, plus requests that
+// carry an Idempotency-Key header; with IdempotencyKeys set, POST and PATCH requests
+// without one are given a random key so they can be retried too.
+type RetryPolicy struct {
+    MaxAttempts     int
+    BackoffInitial  time.Duration
+    BackoffMax      time.Duration
+    Jitter          float64
+    MaxRetryAfter   time.Duration
+    RetryStatuses   []int
+    IdempotencyKeys bool
+}
+
+// CircuitState is the state of a host in a CircuitBreaker.
+type CircuitState string
+
+const (
+    CircuitClosed   CircuitState = "closed"
+    CircuitOpen     CircuitState = "open"
+    CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreaker tracks failures per host. After threshold consecutive failures (transient
+// network errors or 5xx responses) a host's circuit opens and requests fail fast with ErrCircuitOpen.
+// Once the cooldown has passed a single trial request is let through: success closes the
+// circuit, failure opens it again. It is safe for concurrent use.
+type CircuitBreaker struct {
+    threshold int
+    cooldown  time.Duration
+
+    mu    sync.Mutex
+    hosts map[string]*breakerHost
+}
+
+// breakerHost is the circuit state of a single host.
+type breakerHost struct {
+    state    CircuitState
+    failures int
+    openedAt time.Time
+    probing  bool
+}
+
+// NewCircuitBreaker creates a per-host circuit breaker. Zero values use
+// DefaultBreakerThreshold and DefaultBreakerCooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+    if threshold <= 0 {
+        threshold = DefaultBreakerThreshold
+    }
+    if cooldown <= 0 {
+        cooldown = DefaultBreakerCooldown
+    }
+    return &CircuitBreaker{threshold: threshold, cooldown: cooldown, hosts: make(map[string]*breakerHost)}
+}
+
+// State returns the circuit state for host, as seen by the next request.
+func (b *CircuitBreaker) State(host string) CircuitState {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    h, ok := b.hosts[host]
+    if !ok {
+        return CircuitClosed
+    }
+    if h.state == CircuitOpen && time.Since(h.openedAt) >= b.cooldown {
+        return CircuitHalfOpen
+    }
+    return h.state
+}
+
+// allow reports whether a request to host may proceed.
+func (b *CircuitBreaker) allow(host string) error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    h, ok := b.hosts[host]
+    if !ok {
+        return nil
+    }
+    switch h.state {
+    case CircuitOpen:
+        if time.Since(h.openedAt) < b.cooldown {
+            return fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+        }
+        h.state = CircuitHalfOpen
+        h.probing = true
+        return nil
+    case CircuitHalfOpen:
+        if h.probing {
+            return fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+        }
+        h.probing = true
+    }
+    return nil
+}
+
+// record updates the circuit for host with the outcome of a request.
+func (b *CircuitBreaker) record(host string, success bool) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    h, ok := b.hosts[host]
+    if !ok {
+        if success {
+            return
+        }
+        h = &breakerHost{state: CircuitClosed}
+        b.hosts[host] = h
+    }
+
+    h.probing = false
+    if success {
+        delete(b.hosts, host)
+        return
+    }
+    h.failures++
+    if h.state == CircuitHalfOpen || h.failures >= b.threshold {
+        h.state = CircuitOpen
+        h.openedAt = time.Now()
+    }
+}
+
+// abandon releases a half-open trial whose request was cancelled without an outcome.
+func (b *CircuitBreaker) abandon(host string) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    if h, ok := b.hosts[host]; ok {
+        h.probing = false
+    }
+}
+
+// retryTransport is an http.RoundTripper that adds retries and a circuit breaker.
+type retryTransport struct {
+    base    http.RoundTripper
+    policy  RetryPolicy
+    breaker *CircuitBreaker
+}
+
+// NewRetryTransport wraps base (http.DefaultTransport if nil) with retries according to
+// policy and, if breaker is non-nil, a per-host circuit breaker. Install it as the
+// Transport of HTTPClient to make the package-level helpers retry. The client Timeout
+// bounds all attempts together.
+func NewRetryTransport(base http.RoundTripper, policy RetryPolicy, breaker *CircuitBreaker) http.RoundTripper {
+    if base == nil {
+        base = http.DefaultTransport
+    }
+    if policy.MaxAttempts <= 0 {
+        policy.MaxAttempts = DefaultRetryAttempts
+    }
+    if policy.BackoffInitial <= 0 {
+        policy.BackoffInitial = DefaultRetryBackoffInitial
+    }
+    if policy.BackoffMax <= 0 {
+        policy.BackoffMax = DefaultRetryBackoffMax
+    }
+    if policy.Jitter == 0 {
+        policy.Jitter = DefaultRetryJitter
+    }
+    if policy.MaxRetryAfter <= 0 {
+        policy.MaxRetryAfter = DefaultMaxRetryAfter
+    }
+    if len(policy.RetryStatuses) == 0 {
+        policy.RetryStatuses = defaultRetryStatuses
+    }
+    return &retryTransport{base: base, policy: policy, breaker: breaker}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    retryable := isIdempotentMethod(req.Method) || req.Header.Get(IdempotencyKeyHeader) != ""
+    if !retryable && t.policy.IdempotencyKeys && (req.Method == http.MethodPost || req.Method == http.MethodPatch) {
+        req = req.Clone(req.Context())
+        req.Header.Set(IdempotencyKeyHeader, newRandomID())
+        retryable = true
+    }
+    if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+        retryable = false
+    }
+
+    host := req.URL.Host
+    for attempt := 1; ; attempt++ {
+        if t.breaker != nil {
+            if err := t.breaker.allow(host); err != nil {
+                if attempt == 1 && req.Body != nil {
+                    req.Body.Close()
+                }
+                return nil, err
+            }
+        }
+
+        try := req
+        if attempt > 1 && req.GetBody != nil {
+            body, err := req.GetBody()
+            if err != nil {
+                return nil, err
+            }
+            try = req.Clone(req.Context())
+            try.Body = body
+        }
+
+        resp, err := t.base.RoundTrip(try)
+        if t.breaker != nil {
+            if req.Context().Err() != nil || (err != nil && !isTransientNetError(err)) {
+                // No verdict on the host: the caller gave up, or the request itself is bad.
+                t.breaker.abandon(host)
+            } else {
+                t.breaker.record(host, err == nil && resp.StatusCode < 500)
+            }
+        }
+        if !retryable || attempt >= t.policy.MaxAttempts || req.Context().Err() != nil || !t.shouldRetry(resp, err) {
+            return resp, err
+        }
+
+        delay := t.backoff(attempt)
+        if resp != nil {
+            if after, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+                if after > t.policy.MaxRetryAfter {
+                    return resp, nil
+                }
+                delay = after
+            }
+            io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+            resp.Body.Close()
+        }
+
+        timer := time.NewTimer(delay)
+        select {
+        case <-req.Context().Done():
+            timer.Stop()
+            return nil, req.Context().Err()
+        case <-timer.C:
+        }
+    }
+}
+
+// shouldRetry reports whether the outcome of an attempt is worth retrying. Errors are
+// classified like IsRetryable, so only transient network failures are retried.
+func (t *retryTransport) shouldRetry(resp *http.Response, err error) bool {
+    if err != nil {
+        return isTransientNetError(err)
+    }
+    for _, code := range t.policy.RetryStatuses {
+        if resp.StatusCode == code {
+            return true
+        }
+    }
+    return false
+}
+
+// backoff returns the jittered delay before the attempt following attempt n.
+func (t *retryTransport) backoff(n int) time.Duration {
+    delay := t.policy.BackoffInitial
+    for i := 1; i < n && delay < t.policy.BackoffMax; i++ {
+        delay *= 2
+    }
+    if delay > t.policy.BackoffMax {
+        delay = t.policy.BackoffMax
+    }
+    if t.policy.Jitter > 0 {
+        spread := time.Duration(float64(delay) * t.policy.Jitter)
+        delay = delay - spread + time.Duration(mathrand.Int63n(int64(spread)+1))
+    }
+    return delay
+}
+
+// isIdempotentMethod reports whether repeating a request with method has no extra effect.
+func isIdempotentMethod(method string) bool {
+    switch method {
+    case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+        return true
+    }
+    return false
+}
+
+// parseRetryAfter parses a Retry-After value given in seconds or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+    if value == "" {
+        return 0, false
+    }
+    if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+        return time.Duration(secs) * time.Second, true
+    }
+    if at, err := http.ParseTime(value); err == nil {
+        if d := time.Until(at); d > 0 {
+            return d, true
+        }
+        return 0, true
+    }
+    return 0, false
+}
+
+// newRandomID returns a random 128-bit identifier in hex, used for idempotency keys and request IDs.
+func newRandomID() string {
+    b := make([]byte, 16)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
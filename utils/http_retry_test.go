@@ -0,0 +1,164 @@
+package utils
+
+import (
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// fastRetryPolicy retries quickly and without jitter so tests run in milliseconds.
+func fastRetryPolicy() RetryPolicy {
+    return RetryPolicy{MaxAttempts: 3, BackoffInitial: time.Millisecond, BackoffMax: time.Millisecond, Jitter: -1}
+}
+
+// TestRetryTransportRetries5xx checks that 5xx responses are retried until one succeeds.
+func TestRetryTransportRetries5xx(t *testing.T) {
+    var hits int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if atomic.AddInt32(&hits, 1) < 3 {
+            w.WriteHeader(http.StatusServiceUnavailable)
+            return
+        }
+        w.Write([]byte("ok"))
+    }))
+    defer srv.Close()
+
+    client := &http.Client{Transport: NewRetryTransport(nil, fastRetryPolicy(), nil)}
+    resp, err := client.Get(srv.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK || atomic.LoadInt32(&hits) != 3 {
+        t.Fatalf("got status %d after %d attempts, want 200 after 3", resp.StatusCode, hits)
+    }
+}
+
+// TestRetryTransportRetryAfter checks that Retry-After sets the delay, and that a
+// response asking for longer than MaxRetryAfter is returned without retrying.
+func TestRetryTransportRetryAfter(t *testing.T) {
+    var hits int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if atomic.AddInt32(&hits, 1) == 1 || r.URL.Path == "/long" {
+            w.Header().Set("Retry-After", r.URL.Query().Get("after"))
+            w.WriteHeader(http.StatusTooManyRequests)
+        }
+    }))
+    defer srv.Close()
+
+    policy := fastRetryPolicy()
+    policy.MaxRetryAfter = 5 * time.Second
+    client := &http.Client{Transport: NewRetryTransport(nil, policy, nil)}
+
+    start := time.Now()
+    resp, err := client.Get(srv.URL + "/short?after=1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("got status %d, want 200", resp.StatusCode)
+    }
+    if elapsed := time.Since(start); elapsed < time.Second {
+        t.Fatalf("retried after %v, want at least the 1s Retry-After", elapsed)
+    }
+
+    atomic.StoreInt32(&hits, 0)
+    resp, err = client.Get(srv.URL + "/long?after=60")
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusTooManyRequests || atomic.LoadInt32(&hits) != 1 {
+        t.Fatalf("got status %d after %d attempts, want 429 after 1", resp.StatusCode, hits)
+    }
+}
+
+// TestRetryTransportPostNotRetried checks that a POST is only retried when it carries
+// an idempotency key.
+func TestRetryTransportPostNotRetried(t *testing.T) {
+    var hits int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }))
+    defer srv.Close()
+
+    client := &http.Client{Transport: NewRetryTransport(nil, fastRetryPolicy(), nil)}
+    resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("body"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if n := atomic.LoadInt32(&hits); n != 1 {
+        t.Fatalf("POST sent %d times, want 1", n)
+    }
+
+    atomic.StoreInt32(&hits, 0)
+    req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("body"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    req.Header.Set(IdempotencyKeyHeader, "key")
+    resp, err = client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if n := atomic.LoadInt32(&hits); n != 3 {
+        t.Fatalf("POST with idempotency key sent %d times, want 3", n)
+    }
+}
+
+// TestCircuitBreakerOpensAndCloses checks that the breaker opens after the threshold,
+// fails fast while open, and closes again after a successful trial request.
+func TestCircuitBreakerOpensAndCloses(t *testing.T) {
+    var hits int32
+    var healthy atomic.Bool
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+        if !healthy.Load() {
+            w.WriteHeader(http.StatusInternalServerError)
+        }
+    }))
+    defer srv.Close()
+
+    breaker := NewCircuitBreaker(2, 50*time.Millisecond)
+    client := &http.Client{Transport: NewRetryTransport(nil, RetryPolicy{MaxAttempts: 1}, breaker)}
+    host := strings.TrimPrefix(srv.URL, "http://")
+
+    for i := 0; i < 2; i++ {
+        resp, err := client.Get(srv.URL)
+        if err != nil {
+            t.Fatal(err)
+        }
+        resp.Body.Close()
+    }
+    if state := breaker.State(host); state != CircuitOpen {
+        t.Fatalf("state after failures = %s, want open", state)
+    }
+    if _, err := client.Get(srv.URL); !errors.Is(err, ErrCircuitOpen) {
+        t.Fatalf("request while open: got %v, want ErrCircuitOpen", err)
+    }
+    if n := atomic.LoadInt32(&hits); n != 2 {
+        t.Fatalf("server saw %d requests, want 2", n)
+    }
+
+    time.Sleep(60 * time.Millisecond)
+    if state := breaker.State(host); state != CircuitHalfOpen {
+        t.Fatalf("state after cooldown = %s, want half-open", state)
+    }
+    healthy.Store(true)
+    resp, err := client.Get(srv.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if state := breaker.State(host); state != CircuitClosed {
+        t.Fatalf("state after successful trial = %s, want closed", state)
+    }
+}
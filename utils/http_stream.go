@@ -0,0 +1,215 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// ErrChecksumMismatch is returned by DownloadFile when the downloaded file has the wrong digest.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// HTTPResponse is a response whose body is streamed rather than read into memory.
+// The caller must close Body.
+type HTTPResponse struct {
+    StatusCode    int
+    Status        string
+    Header        http.Header
+    ContentLength int64
+    Body          io.ReadCloser
+}
+
+// DownloadOptions configures DownloadFile.
+//
+// With Resume, an existing dst+".part" file left by an interrupted download is continued
+// with a Range request. Progress is called as data arrives with the bytes written so far
+// and the total size, or -1 if unknown. If Checksum is set, the finished file is verified
+// with ChecksumAlgorithm (SHA-256 by default) before it is renamed into place.
+type DownloadOptions struct {
+    Headers           map[string]string
+    Resume            bool
+    Progress          func(done, total int64)
+    Checksum          string
+    ChecksumAlgorithm HashAlgorithm
+    Perm              os.FileMode
+}
+
+// Do sends a request with a streamed body and returns the response without reading it.
+// The client's overall Timeout does not apply, so long transfers are bounded by ctx alone.
+// Responses with status >= 400 are returned as an *HTTPError with the body already closed.
+func (c *RESTClient) Do(ctx context.Context, method, url string, headers map[string]string, body io.Reader) (*HTTPResponse, error) {
+    resp, err := c.send(ctx, c.streamClient(), method, url, headers, body)
+    if err != nil {
+        return nil, err
+    }
+
+    if resp.StatusCode >= 400 {
+        defer resp.Body.Close()
+        return nil, readHTTPError(resp)
+    }
+
+    return &HTTPResponse{
+        StatusCode:    resp.StatusCode,
+        Status:        resp.Status,
+        Header:        resp.Header,
+        ContentLength: resp.ContentLength,
+        Body:          resp.Body,
+    }, nil
+}
+
+// DownloadFile streams url to dst through a temporary dst+".part" file, which is renamed
+// into place only once the download is complete and, if requested, verified.
+func (c *RESTClient) DownloadFile(ctx context.Context, url, dst string, opts DownloadOptions) error {
+    if opts.Perm == 0 {
+        opts.Perm = 0644
+    }
+    if opts.ChecksumAlgorithm == "" {
+        opts.ChecksumAlgorithm = HashSHA256
+    }
+
+    part := dst + ".part"
+    var offset int64
+    if opts.Resume {
+        if info, err := os.Stat(part); err == nil {
+            offset = info.Size()
+        }
+    }
+
+    headers := make(map[string]string, len(opts.Headers)+1)
+    for key, val := range opts.Headers {
+        headers[key] = val
+    }
+    if offset > 0 {
+        headers["Range"] = fmt.Sprintf("bytes=%d-", offset)
+    }
+
+    resp, err := c.send(ctx, c.streamClient(), http.MethodGet, url, headers, nil)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    total := resp.ContentLength
+    flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+    switch {
+    case resp.StatusCode == http.StatusPartialContent && offset > 0:
+        start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+        if !ok || start != offset {
+            return fmt.Errorf("download %s: unexpected Content-Range %q", url, resp.Header.Get("Content-Range"))
+        }
+        flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+        total = size
+    case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+        // The partial file is stale or already complete; start over.
+        resp.Body.Close()
+        if err := os.Remove(part); err != nil {
+            return err
+        }
+        return c.DownloadFile(ctx, url, dst, opts)
+    case resp.StatusCode >= 400:
+        return readHTTPError(resp)
+    default:
+        // The server ignored the Range header and sent the whole file.
+        offset = 0
+    }
+    if total >= 0 && resp.StatusCode != http.StatusPartialContent {
+        total += offset
+    }
+
+    f, err := os.OpenFile(part, flag, opts.Perm)
+    if err != nil {
+        return err
+    }
+
+    done := offset
+    if opts.Progress != nil {
+        opts.Progress(done, total)
+    }
+    w := &progressWriter{w: f, onWrite: func(n int64) {
+        done += n
+        if opts.Progress != nil {
+            opts.Progress(done, total)
+        }
+    }}
+    if _, err := io.Copy(w, resp.Body); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Sync(); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return err
+    }
+    if total >= 0 && done != total {
+        return fmt.Errorf("download %s: got %d of %d bytes", url, done, total)
+    }
+
+    if opts.Checksum != "" {
+        sum, err := HashFile(part, opts.ChecksumAlgorithm)
+        if err != nil {
+            return err
+        }
+        if !strings.EqualFold(sum, opts.Checksum) {
+            os.Remove(part)
+            return fmt.Errorf("download %s: %w: got %s %s, want %s", url, ErrChecksumMismatch, opts.ChecksumAlgorithm, sum, opts.Checksum)
+        }
+    }
+    return os.Rename(part, dst)
+}
+
+// readHTTPError reads up to MaxHTTPErrorBody bytes of an error response into an HTTPError.
+// A failed read still yields the error with whatever part of the body arrived.
+func readHTTPError(resp *http.Response) error {
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, MaxHTTPErrorBody))
+    return newHTTPError(resp, body)
+}
+
+// streamClient returns a copy of the underlying client without the overall timeout,
+// which would otherwise cut off long-running body reads.
+func (c *RESTClient) streamClient() *http.Client {
+    client := *c.httpClient()
+    client.Timeout = 0
+    return &client
+}
+
+// parseContentRange parses "bytes start-end/size", returning size -1 when it is "*".
+func parseContentRange(value string) (int64, int64, bool) {
+    value = strings.TrimPrefix(value, "bytes ")
+    span, sizeText, ok := strings.Cut(value, "/")
+    if !ok {
+        return 0, 0, false
+    }
+    startText, _, ok := strings.Cut(span, "-")
+    if !ok {
+        return 0, 0, false
+    }
+    start, err := strconv.ParseInt(startText, 10, 64)
+    if err != nil {
+        return 0, 0, false
+    }
+    if sizeText == "*" {
+        return start, -1, true
+    }
+    size, err := strconv.ParseInt(sizeText, 10, 64)
+    if err != nil {
+        return 0, 0, false
+    }
+    return start, size, true
+}
+
+// HTTPDo sends a streaming request with the default client; see RESTClient.Do.
+func HTTPDo(ctx context.Context, method, url string, headers map[string]string, body io.Reader) (*HTTPResponse, error) {
+    return defaultRESTClient.Do(ctx, method, url, headers, body)
+}
+
+// DownloadFile downloads url to dst with the default client; see RESTClient.DownloadFile.
+func DownloadFile(ctx context.Context, url, dst string, opts DownloadOptions) error {
+    return defaultRESTClient.DownloadFile(ctx, url, dst, opts)
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+// TestRESTClientResolveQueryURL checks that a relative path carrying a URL in its
+// query is still joined onto the base URL.
+func TestRESTClientResolveQueryURL(t *testing.T) {
+    var gotPath, gotNext string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        gotPath = r.URL.Path
+        gotNext = r.URL.Query().Get("next")
+    }))
+    defer srv.Close()
+
+    c, err := NewRESTClient(RESTClientOptions{BaseURL: srv.URL + "/api/"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, _, err := c.Get("/login?next=https://example.com/x", nil); err != nil {
+        t.Fatal(err)
+    }
+    if gotPath != "/api/login" || gotNext != "https://example.com/x" {
+        t.Fatalf("got path %q, next %q", gotPath, gotNext)
+    }
+
+    if got := c.resolve("https://example.com/x"); got != "https://example.com/x" {
+        t.Fatalf("absolute URL resolved to %q", got)
+    }
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
     "fmt"
+    "io"
     "log"
     "os"
     "time"
@@ -36,6 +37,19 @@ func NewLogger(namespace, subject string) *Logger {
     }
 }
 
+// NewLoggerWithWriter returns a logger like NewLogger that writes to w instead of stdout,
+// for example a RotatingWriter.
+func NewLoggerWithWriter(namespace, subject string, w io.Writer) *Logger {
+    l := NewLogger(namespace, subject)
+    l.logger.SetOutput(w)
+    return l
+}
+
+// SetOutput redirects the logger's output to w.
+func (l *Logger) SetOutput(w io.Writer) {
+    l.logger.SetOutput(w)
+}
+
 // logf outputs a log message with timestamp and level, systemd-readable.
 func (l *Logger) logf(level LogLevel, format string, args ...interface{}) {
     timestamp := time.Now().Format(time.RFC3339)
@@ -0,0 +1,358 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os/exec"
+    "sort"
+    "sync"
+    "time"
+)
+
+// RestartPolicy defines when a supervised process is restarted after it exits.
+type RestartPolicy string
+
+const (
+    RestartAlways    RestartPolicy = "always"
+    RestartOnFailure RestartPolicy = "on-failure"
+    RestartNever     RestartPolicy = "never"
+)
+
+// ProcessState describes the lifecycle state of a supervised process.
+type ProcessState string
+
+const (
+    ProcessStarting ProcessState = "starting"
+    ProcessRunning  ProcessState = "running"
+    ProcessBackoff  ProcessState = "backoff"
+    ProcessStopping ProcessState = "stopping"
+    ProcessStopped  ProcessState = "stopped"
+    ProcessExited   ProcessState = "exited"
+    ProcessFailed   ProcessState = "failed"
+)
+
+// Default supervisor timings used when a ProcessSpec leaves them unset.
+const (
+    DefaultBackoffInitial = 1 * time.Second
+    DefaultBackoffMax     = 1 * time.Minute
+    DefaultStopGrace      = 10 * time.Second
+)
+
+// ProcessSpec describes a named command managed by a Supervisor.
+// MaxRetries of 0 means unlimited restarts.
+type ProcessSpec struct {
+    Name           string
+    Command        *Command
+    Policy         RestartPolicy
+    MaxRetries     int
+    BackoffInitial time.Duration
+    BackoffMax     time.Duration
+    StopGrace      time.Duration
+}
+
+// ProcessStatus is a point-in-time snapshot of a supervised process, suitable for health reporting.
+type ProcessStatus struct {
+    Name         string       `json:"name"`
+    State        ProcessState `json:"state"`
+    PID          int          `json:"pid"`
+    Restarts     int          `json:"restarts"`
+    LastExitCode int          `json:"last_exit_code"`
+    LastError    string       `json:"last_error,omitempty"`
+    StartedAt    time.Time    `json:"started_at"`
+    ExitedAt     time.Time    `json:"exited_at"`
+}
+
+// Supervisor starts named commands in the background, forwards their output to a Logger,
+// and restarts them according to their RestartPolicy.
+type Supervisor struct {
+    mu        sync.Mutex
+    namespace string
+    logger    *Logger
+    procs     map[string]*supervisedProcess
+}
+
+// supervisedProcess holds the runtime state of a single managed command.
+type supervisedProcess struct {
+    mu     sync.Mutex
+    spec   ProcessSpec
+    status ProcessStatus
+    cmd    *exec.Cmd
+    exited chan struct{}
+    stop   chan struct{}
+    done   chan struct{}
+}
+
+// NewSupervisor returns a Supervisor whose processes log under the given namespace,
+// each with its process name as LOG_SUBJECT.
+func NewSupervisor(namespace string) *Supervisor {
+    return &Supervisor{
+        namespace: namespace,
+        logger:    NewLogger(namespace, "supervisor"),
+        procs:     make(map[string]*supervisedProcess),
+    }
+}
+
+// Start launches a process described by spec and keeps it running according to its policy.
+// Starting a name that is still active returns an error.
+func (s *Supervisor) Start(spec ProcessSpec) error {
+    if spec.Name == "" {
+        return errors.New("process name must not be empty")
+    }
+    if spec.Command == nil || spec.Command.program == "" {
+        return fmt.Errorf("process %s has no command", spec.Name)
+    }
+    if spec.Policy == "" {
+        spec.Policy = RestartOnFailure
+    }
+    if spec.BackoffInitial <= 0 {
+        spec.BackoffInitial = DefaultBackoffInitial
+    }
+    if spec.BackoffMax <= 0 {
+        spec.BackoffMax = DefaultBackoffMax
+    }
+    if spec.StopGrace <= 0 {
+        spec.StopGrace = DefaultStopGrace
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if existing, ok := s.procs[spec.Name]; ok {
+        select {
+        case <-existing.done:
+        default:
+            return fmt.Errorf("process %s is already supervised", spec.Name)
+        }
+    }
+
+    p := &supervisedProcess{
+        spec:   spec,
+        status: ProcessStatus{Name: spec.Name, State: ProcessStarting, LastExitCode: -1},
+        stop:   make(chan struct{}),
+        done:   make(chan struct{}),
+    }
+    s.procs[spec.Name] = p
+    go s.supervise(p, NewLogger(s.namespace, spec.Name))
+    return nil
+}
+
+// Stop gracefully stops a supervised process: SIGTERM to its process group, then SIGKILL
+// once the grace period expires. It blocks until the process has exited.
+func (s *Supervisor) Stop(name string) error {
+    s.mu.Lock()
+    p, ok := s.procs[name]
+    s.mu.Unlock()
+    if !ok {
+        return fmt.Errorf("process %s is not supervised", name)
+    }
+
+    p.mu.Lock()
+    select {
+    case <-p.stop:
+    default:
+        close(p.stop)
+    }
+    cmd, exited := p.cmd, p.exited
+    if cmd != nil {
+        p.status.State = ProcessStopping
+    }
+    p.mu.Unlock()
+
+    // Once exited is closed the process has been reaped and its PID may already belong to
+    // someone else, so it is checked again before every signal.
+    if cmd != nil {
+        select {
+        case <-exited:
+        default:
+            _ = signalProcess(cmd, false)
+            select {
+            case <-exited:
+            case <-time.After(p.spec.StopGrace):
+                select {
+                case <-exited:
+                default:
+                    s.logger.Warnf("process %s did not stop within %s, killing", name, p.spec.StopGrace)
+                    _ = signalProcess(cmd, true)
+                }
+            }
+        }
+    }
+
+    <-p.done
+    return nil
+}
+
+// StopAll stops every supervised process concurrently and waits for them to exit.
+func (s *Supervisor) StopAll() {
+    s.mu.Lock()
+    names := make([]string, 0, len(s.procs))
+    for name := range s.procs {
+        names = append(names, name)
+    }
+    s.mu.Unlock()
+
+    var wg sync.WaitGroup
+    for _, name := range names {
+        wg.Add(1)
+        go func(name string) {
+            defer wg.Done()
+            _ = s.Stop(name)
+        }(name)
+    }
+    wg.Wait()
+}
+
+// Status returns the current status of a single process.
+func (s *Supervisor) Status(name string) (ProcessStatus, bool) {
+    s.mu.Lock()
+    p, ok := s.procs[name]
+    s.mu.Unlock()
+    if !ok {
+        return ProcessStatus{}, false
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.status, true
+}
+
+// Snapshot returns the status of every supervised process, sorted by name.
+func (s *Supervisor) Snapshot() []ProcessStatus {
+    s.mu.Lock()
+    procs := make([]*supervisedProcess, 0, len(s.procs))
+    for _, p := range s.procs {
+        procs = append(procs, p)
+    }
+    s.mu.Unlock()
+
+    statuses := make([]ProcessStatus, 0, len(procs))
+    for _, p := range procs {
+        p.mu.Lock()
+        statuses = append(statuses, p.status)
+        p.mu.Unlock()
+    }
+    sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+    return statuses
+}
+
+// supervise runs p until it is stopped or its restart policy gives up.
+func (s *Supervisor) supervise(p *supervisedProcess, logger *Logger) {
+    defer close(p.done)
+
+    backoff := p.spec.BackoffInitial
+    for {
+        startedAt := time.Now()
+        exitCode, err := s.runOnce(p, logger)
+        ranFor := time.Since(startedAt)
+
+        p.mu.Lock()
+        p.cmd = nil
+        p.status.PID = 0
+        p.status.LastExitCode = exitCode
+        p.status.ExitedAt = time.Now()
+        p.status.LastError = ""
+        if err != nil {
+            p.status.LastError = err.Error()
+        }
+
+        select {
+        case <-p.stop:
+            p.status.State = ProcessStopped
+            p.mu.Unlock()
+            s.logger.Infof("process %s stopped", p.spec.Name)
+            return
+        default:
+        }
+
+        if !p.shouldRestart(err) {
+            if err != nil {
+                p.status.State = ProcessFailed
+            } else {
+                p.status.State = ProcessExited
+            }
+            p.mu.Unlock()
+            s.logger.Infof("process %s exited with code %d, not restarting", p.spec.Name, exitCode)
+            return
+        }
+
+        // A process that stayed up longer than the maximum backoff is considered healthy again.
+        if ranFor > p.spec.BackoffMax {
+            backoff = p.spec.BackoffInitial
+        }
+        p.status.State = ProcessBackoff
+        p.status.Restarts++
+        p.mu.Unlock()
+
+        s.logger.Warnf("process %s exited with code %d, restarting in %s", p.spec.Name, exitCode, backoff)
+        select {
+        case <-p.stop:
+            p.mu.Lock()
+            p.status.State = ProcessStopped
+            p.mu.Unlock()
+            return
+        case <-time.After(backoff):
+        }
+
+        backoff *= 2
+        if backoff > p.spec.BackoffMax {
+            backoff = p.spec.BackoffMax
+        }
+    }
+}
+
+// runOnce starts the process, forwards its output to logger, and waits for it to exit.
+func (s *Supervisor) runOnce(p *supervisedProcess, logger *Logger) (int, error) {
+    em := &lineEmitter{onLine: StreamToLogger(logger)}
+    stdout := &lineWriter{stream: StreamStdout, emitter: em}
+    stderr := &lineWriter{stream: StreamStderr, emitter: em}
+
+    ctx := context.Background()
+    cmd, err := p.spec.Command.build(ctx)
+    if err != nil {
+        return -1, err
+    }
+    cmd.Stdout = stdout
+    cmd.Stderr = stderr
+
+    p.mu.Lock()
+    select {
+    case <-p.stop:
+        p.mu.Unlock()
+        return -1, nil
+    default:
+    }
+    p.status.State = ProcessStarting
+    if err := cmd.Start(); err != nil {
+        p.mu.Unlock()
+        return -1, err
+    }
+    p.cmd = cmd
+    p.exited = make(chan struct{})
+    p.status.State = ProcessRunning
+    p.status.PID = cmd.Process.Pid
+    p.status.StartedAt = time.Now()
+    exited := p.exited
+    p.mu.Unlock()
+
+    s.logger.Infof("process %s started with pid %d", p.spec.Name, cmd.Process.Pid)
+    err = classifyCommandError(ctx, cmd.Wait(), p.spec.Command.String())
+    close(exited)
+    stdout.flush()
+    stderr.flush()
+
+    return exitCodeOf(cmd), err
+}
+
+// shouldRestart applies the restart policy and retry limit. Callers must hold p.mu.
+func (p *supervisedProcess) shouldRestart(err error) bool {
+    switch p.spec.Policy {
+    case RestartNever:
+        return false
+    case RestartOnFailure:
+        if err == nil {
+            return false
+        }
+    }
+    return p.spec.MaxRetries == 0 || p.status.Restarts < p.spec.MaxRetries
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+    "runtime"
+    "testing"
+    "time"
+)
+
+// skipWithoutBash skips tests that run bash commands.
+func skipWithoutBash(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("needs bash")
+    }
+}
+
+// waitForState polls the status of name until it reaches state.
+func waitForState(t *testing.T, s *Supervisor, name string, state ProcessState) ProcessStatus {
+    t.Helper()
+    deadline := time.Now().Add(10 * time.Second)
+    for {
+        status, ok := s.Status(name)
+        if ok && status.State == state {
+            return status
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("process %s is %s, want %s", name, status.State, state)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+// TestSupervisorMaxRetriesBackoff checks that a failing process is restarted MaxRetries
+// times with doubling backoff and then marked failed.
+func TestSupervisorMaxRetriesBackoff(t *testing.T) {
+    skipWithoutBash(t)
+    s := NewSupervisor("test")
+    defer s.StopAll()
+
+    start := time.Now()
+    err := s.Start(ProcessSpec{
+        Name:           "flaky",
+        Command:        NewCommand("bash", "-c", "exit 3"),
+        Policy:         RestartOnFailure,
+        MaxRetries:     3,
+        BackoffInitial: 40 * time.Millisecond,
+        BackoffMax:     time.Second,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    status := waitForState(t, s, "flaky", ProcessFailed)
+    if status.Restarts != 3 || status.LastExitCode != 3 {
+        t.Fatalf("got %d restarts and exit code %d, want 3 and 3", status.Restarts, status.LastExitCode)
+    }
+    if elapsed := time.Since(start); elapsed < 280*time.Millisecond {
+        t.Fatalf("three restarts took %v, want at least 40+80+160ms of backoff", elapsed)
+    }
+}
+
+// TestSupervisorRestartPolicies checks which exits each policy restarts.
+func TestSupervisorRestartPolicies(t *testing.T) {
+    skipWithoutBash(t)
+    cases := []struct {
+        name     string
+        policy   RestartPolicy
+        command  string
+        state    ProcessState
+        restarts int
+    }{
+        {"on-failure-success", RestartOnFailure, "exit 0", ProcessExited, 0},
+        {"never-failure", RestartNever, "exit 1", ProcessFailed, 0},
+        {"always-success", RestartAlways, "exit 0", ProcessExited, 2},
+    }
+
+    s := NewSupervisor("test")
+    defer s.StopAll()
+    for _, c := range cases {
+        err := s.Start(ProcessSpec{
+            Name:           c.name,
+            Command:        NewCommand("bash", "-c", c.command),
+            Policy:         c.policy,
+            MaxRetries:     2,
+            BackoffInitial: 10 * time.Millisecond,
+        })
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    for _, c := range cases {
+        status := waitForState(t, s, c.name, c.state)
+        if status.Restarts != c.restarts {
+            t.Fatalf("%s: got %d restarts, want %d", c.name, status.Restarts, c.restarts)
+        }
+    }
+}
+
+// TestSupervisorStopGraceThenKill checks that a process ignoring SIGTERM is killed once
+// StopGrace expires, and that a well-behaved one stops straight away.
+func TestSupervisorStopGraceThenKill(t *testing.T) {
+    skipWithoutBash(t)
+    s := NewSupervisor("test")
+    defer s.StopAll()
+
+    specs := []ProcessSpec{
+        {Name: "stubborn", Command: NewCommand("bash", "-c", "trap '' TERM; sleep 30"), StopGrace: 300 * time.Millisecond},
+        {Name: "polite", Command: NewCommand("bash", "-c", "sleep 30"), StopGrace: 10 * time.Second},
+    }
+    for _, spec := range specs {
+        if err := s.Start(spec); err != nil {
+            t.Fatal(err)
+        }
+        waitForState(t, s, spec.Name, ProcessRunning)
+    }
+    // Give bash time to install its trap.
+    time.Sleep(200 * time.Millisecond)
+
+    start := time.Now()
+    if err := s.Stop("stubborn"); err != nil {
+        t.Fatal(err)
+    }
+    if elapsed := time.Since(start); elapsed < 300*time.Millisecond || elapsed > 5*time.Second {
+        t.Fatalf("stubborn process stopped after %v, want just over the 300ms grace", elapsed)
+    }
+
+    start = time.Now()
+    if err := s.Stop("polite"); err != nil {
+        t.Fatal(err)
+    }
+    if elapsed := time.Since(start); elapsed > 5*time.Second {
+        t.Fatalf("polite process took %v to stop", elapsed)
+    }
+
+    for _, spec := range specs {
+        if status, _ := s.Status(spec.Name); status.State != ProcessStopped || status.PID != 0 {
+            t.Fatalf("%s: got state %s pid %d after Stop", spec.Name, status.State, status.PID)
+        }
+    }
+}
+
+// TestSupervisorSnapshot checks that Snapshot reports every process sorted by name, and
+// that stopping an exited process does not signal anything.
+func TestSupervisorSnapshot(t *testing.T) {
+    skipWithoutBash(t)
+    s := NewSupervisor("test")
+    defer s.StopAll()
+
+    for _, name := range []string{"b", "a"} {
+        if err := s.Start(ProcessSpec{Name: name, Command: NewCommand("bash", "-c", "sleep 30")}); err != nil {
+            t.Fatal(err)
+        }
+        waitForState(t, s, name, ProcessRunning)
+    }
+    if err := s.Start(ProcessSpec{Name: "c", Command: NewCommand("bash", "-c", "exit 0")}); err != nil {
+        t.Fatal(err)
+    }
+    waitForState(t, s, "c", ProcessExited)
+
+    snapshot := s.Snapshot()
+    if len(snapshot) != 3 || snapshot[0].Name != "a" || snapshot[1].Name != "b" || snapshot[2].Name != "c" {
+        t.Fatalf("got snapshot %+v", snapshot)
+    }
+    for _, status := range snapshot[:2] {
+        if status.State != ProcessRunning || status.PID == 0 || status.StartedAt.IsZero() {
+            t.Fatalf("got %+v, want a running process", status)
+        }
+    }
+    if snapshot[2].PID != 0 || snapshot[2].LastExitCode != 0 {
+        t.Fatalf("got %+v, want an exited process", snapshot[2])
+    }
+
+    if err := s.Stop("c"); err != nil {
+        t.Fatal(err)
+    }
+    s.StopAll()
+    for _, status := range s.Snapshot() {
+        if status.Name != "c" && status.State != ProcessStopped {
+            t.Fatalf("got %+v after StopAll", status)
+        }
+    }
+    if err := s.Start(ProcessSpec{Name: "a", Command: NewCommand("bash", "-c", "exit 0")}); err != nil {
+        t.Fatalf("restarting a stopped name: %v", err)
+    }
+}